@@ -0,0 +1,101 @@
+package mmaconv
+
+import (
+	"errors"
+	"math"
+)
+
+// StandardGravity (m/s2) is used to convert the lever arm correction into
+// micro g.
+const StandardGravity = 9.80665
+
+var ErrMatrix = errors.New("rotation matrix should be 3x3")
+
+// Alignment gives the orientation of the sensor axes in the station body
+// frame either with a rotation matrix or with Euler angles (degrees, applied in
+// the yaw, pitch, roll order). The matrix takes precedence over the angles
+// when both are given.
+//
+// Lever is the position (meters) of the sensor relative to the reference point
+// of the station and Rate is the angular velocity (rad/s) of the station, both
+// in the station body frame. They are used to remove the centripetal
+// acceleration seen by the sensor.
+type Alignment struct {
+	Matrix [][]float64
+	Roll   float64
+	Pitch  float64
+	Yaw    float64
+
+	Lever XYZ
+	Rate  XYZ
+}
+
+// Rotation gives the matrix rotating vectors from the sensor frame into the
+// station body frame.
+func (a Alignment) Rotation() ([3][3]float64, error) {
+	var rot [3][3]float64
+	if len(a.Matrix) > 0 {
+		if len(a.Matrix) != 3 {
+			return rot, ErrMatrix
+		}
+		for i, row := range a.Matrix {
+			if len(row) != 3 {
+				return rot, ErrMatrix
+			}
+			copy(rot[i][:], row)
+		}
+		return rot, nil
+	}
+	var (
+		sr, cr = math.Sincos(a.Roll * math.Pi / 180)
+		sp, cp = math.Sincos(a.Pitch * math.Pi / 180)
+		sy, cy = math.Sincos(a.Yaw * math.Pi / 180)
+	)
+	rot[0] = [3]float64{cy * cp, (cy * sp * sr) - (sy * cr), (cy * sp * cr) + (sy * sr)}
+	rot[1] = [3]float64{sy * cp, (sy * sp * sr) + (cy * cr), (sy * sp * cr) - (cy * sr)}
+	rot[2] = [3]float64{-sp, cp * sr, cp * cr}
+	return rot, nil
+}
+
+// Centripetal gives the centripetal acceleration (micro g) seen at the
+// position of the sensor in the station body frame.
+func (a Alignment) Centripetal() XYZ {
+	var (
+		w = a.Rate
+		r = a.Lever
+		// w x r
+		x = (w.Y * r.Z) - (w.Z * r.Y)
+		y = (w.Z * r.X) - (w.X * r.Z)
+		z = (w.X * r.Y) - (w.Y * r.X)
+		f = 1e6 / StandardGravity
+	)
+	// w x (w x r)
+	return XYZ{
+		X: ((w.Y * z) - (w.Z * y)) * f,
+		Y: ((w.Z * x) - (w.X * z)) * f,
+		Z: ((w.X * y) - (w.Y * x)) * f,
+	}
+}
+
+// Align sets the accelerations of the measurement in the station body frame.
+func (a Alignment) Align(m *Measurement) error {
+	rot, err := a.Rotation()
+	if err != nil {
+		return err
+	}
+	c := a.Centripetal()
+	m.BodyX = make([]float64, len(m.AccX))
+	m.BodyY = make([]float64, len(m.AccY))
+	m.BodyZ = make([]float64, len(m.AccZ))
+	for i := range m.AccX {
+		v := [3]float64{m.AccX[i], m.AccY[i], m.AccZ[i]}
+		m.BodyX[i] = dot(rot[0], v) - c.X
+		m.BodyY[i] = dot(rot[1], v) - c.Y
+		m.BodyZ[i] = dot(rot[2], v) - c.Z
+	}
+	return nil
+}
+
+func dot(a, b [3]float64) float64 {
+	return (a[0] * b[0]) + (a[1] * b[1]) + (a[2] * b[2])
+}
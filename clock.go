@@ -0,0 +1,155 @@
+package mmaconv
+
+import (
+	"math"
+	"time"
+)
+
+// TickRate is the rate, in Hz, at which the milbus sequence counter is
+// incremented.
+const TickRate = 1500
+
+// maxDrift is the relative difference accepted between the fitted and the
+// nominal rate of the sample clock.
+const maxDrift = 0.01
+
+type clockPoint struct {
+	count int64
+	when  time.Time
+}
+
+// Clock models the sample clock of the sensor. It fits a line between the
+// unwrapped sequence counter of the first record of consecutive files and
+// their acquisition times. Only the last files added are kept to follow the
+// drift of the clock.
+type Clock struct {
+	step   int64
+	size   int
+	points []clockPoint
+
+	origin clockPoint
+	period float64
+	rms    float64
+}
+
+// NewClock creates a Clock for a sensor sampling at freq Hz and using the size
+// last files for its estimation.
+func NewClock(freq int64, size int) *Clock {
+	if size < 2 {
+		size = 2
+	}
+	step, ok := Frequencies[freq]
+	if !ok {
+		step = MeasCount
+	}
+	return &Clock{
+		step:   step,
+		size:   size,
+		period: 1.0 / TickRate,
+	}
+}
+
+func (c *Clock) Reset() {
+	c.points = c.points[:0]
+	c.period = 1.0 / TickRate
+	c.rms = 0
+}
+
+func (c *Clock) Add(count int64, when time.Time) {
+	if len(c.points) >= c.size {
+		c.points = append(c.points[:0], c.points[1:]...)
+	}
+	c.points = append(c.points, clockPoint{count: count, when: when})
+	c.fit()
+}
+
+// Update adds the measurements of a file to the clock. The clock is reset
+// when the sequence counter has been reset and the first dated measurement of
+// the file gives its point.
+func (c *Clock) Update(ms []Measurement) {
+	for _, m := range ms {
+		if m.Reason == ReasonReset {
+			c.Reset()
+			break
+		}
+	}
+	for _, m := range ms {
+		if !m.NoDate {
+			c.Add(m.Count, m.When)
+			break
+		}
+	}
+}
+
+// Time gives the time of the record having the given unwrapped sequence
+// counter.
+func (c *Clock) Time(count int64) time.Time {
+	secs := float64(count-c.origin.count) * c.period
+	return c.origin.when.Add(time.Duration(secs * float64(time.Second)))
+}
+
+// Sample gives the time of the nth sample of a record.
+func (c *Clock) Sample(count int64, n int) time.Time {
+	return c.Time(count + (int64(n) * c.step / MeasCount))
+}
+
+// Period gives the estimated time between two ticks of the sequence counter.
+func (c *Clock) Period() time.Duration {
+	return time.Duration(c.period * float64(time.Second))
+}
+
+// RMS gives the root mean square of the differences between the acquisition
+// times of the files and the fitted clock.
+func (c *Clock) RMS() time.Duration {
+	return time.Duration(c.rms * float64(time.Second))
+}
+
+// Residual gives the difference between the time of the nth sample of a record
+// at the nominal rate from the acquisition time of its file, whose first record
+// has the unwrapped counter first, and its time given by the fitted clock.
+func (c *Clock) Residual(when time.Time, first, count int64, n int) time.Duration {
+	var (
+		ticks = count + (int64(n) * c.step / MeasCount) - first
+		secs  = float64(ticks) / TickRate
+	)
+	return when.Add(time.Duration(secs * float64(time.Second))).Sub(c.Sample(count, n))
+}
+
+func (c *Clock) fit() {
+	var (
+		n      = float64(len(c.points))
+		first  = c.points[0]
+		mx, my float64
+	)
+	for _, p := range c.points {
+		mx += float64(p.count - first.count)
+		my += p.when.Sub(first.when).Seconds()
+	}
+	mx, my = mx/n, my/n
+
+	var sxy, sxx float64
+	for _, p := range c.points {
+		x := float64(p.count-first.count) - mx
+		y := p.when.Sub(first.when).Seconds() - my
+		sxy += x * y
+		sxx += x * x
+	}
+	period := 1.0 / TickRate
+	if sxx > 0 {
+		if p := sxy / sxx; math.Abs(p*TickRate-1) <= maxDrift {
+			period = p
+		}
+	}
+	c.period = period
+	c.origin = clockPoint{
+		count: first.count,
+		when:  first.when.Add(time.Duration((my - period*mx) * float64(time.Second))),
+	}
+
+	var sum float64
+	for _, p := range c.points {
+		d := p.when.Sub(c.Time(p.count)).Seconds()
+		sum += d * d
+	}
+	c.rms = math.Sqrt(sum / n)
+}
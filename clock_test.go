@@ -0,0 +1,66 @@
+package mmaconv
+
+import (
+	"testing"
+	"time"
+)
+
+// measurements gives the measurements of the records with only their
+// position on the timeline.
+func measurements(rs []Record) []Measurement {
+	ms := make([]Measurement, len(rs))
+	for i := range rs {
+		ms[i].Record = rs[i]
+	}
+	return ms
+}
+
+func TestClockConsecutiveFiles(t *testing.T) {
+	const (
+		files = 8
+		drift = 0.0005
+	)
+	data := []struct {
+		Freq  int64
+		Count int
+	}{
+		{Freq: 1500, Count: 220},
+		{Freq: 150, Count: 220},
+		{Freq: 50, Count: 219},
+		{Freq: 5, Count: 30},
+	}
+	for _, d := range data {
+		var (
+			tl    = NewTimeline(d.Freq)
+			clock = NewClock(d.Freq, files)
+			step  = Frequencies[d.Freq]
+			rate  = TickRate * (1 + drift)
+			seq   = uint16(1000)
+			ticks int64
+		)
+		at := func(ticks int64) time.Time {
+			return timelineEpoch.Add(time.Duration(float64(ticks) / rate * float64(time.Second)))
+		}
+		for i := 0; i < files; i++ {
+			rs := tl.Order(makeFile(uint32(i+1), at(ticks), d.Freq, seq, d.Count))
+			clock.Update(measurements(rs))
+
+			ticks += int64(d.Count) * step
+			seq += uint16(int64(d.Count) * step)
+		}
+		if got := clock.Period().Seconds() * rate; got < 0.999999 || got > 1.000001 {
+			t.Errorf("%d Hz: period want %s, got %s", d.Freq, time.Duration(float64(time.Second)/rate), clock.Period())
+		}
+		var (
+			first = int64(1000)
+			last  = first + ticks - step
+			want  = at(ticks - step)
+		)
+		if got := clock.Sample(last, 0); got.Sub(want) > time.Millisecond || want.Sub(got) > time.Millisecond {
+			t.Errorf("%d Hz: time of the last record want %s, got %s", d.Freq, want, got)
+		}
+		if rms := clock.RMS(); rms > time.Millisecond {
+			t.Errorf("%d Hz: rms too large: %s", d.Freq, rms)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/busoc/mmaconv"
@@ -20,7 +21,11 @@ const (
 )
 
 func main() {
-	mindur := flag.Duration("d", Diff, "check for difference greater than the given duration")
+	var (
+		mindur = flag.Duration("d", Diff, "check for difference greater than the given duration")
+		scale  mmaconv.TimeScale
+	)
+	flag.Var(&scale, "s", "time scale used to report times (gps, utc, tai)")
 	flag.Parse()
 
 	var r io.Reader = os.Stdin
@@ -37,13 +42,18 @@ func main() {
 			r = z
 		} else {
 			f.Seek(0, io.SeekStart)
+			r = f
 		}
 	}
 
 	rs := csv.NewReader(r)
 	rs.Comment = '#'
 	rs.Comma = ','
-	rs.Read()
+
+	var from mmaconv.TimeScale
+	if row, err := rs.Read(); err == nil && len(row) > 0 {
+		from = getScale(row[0])
+	}
 
 	var (
 		prev time.Time
@@ -54,11 +64,13 @@ func main() {
 		if row == nil || err != nil {
 			break
 		}
-		prev, last = check(row, i, prev, last, *mindur)
+		prev, last = check(row, i, prev, last, *mindur, func(t time.Time) time.Time {
+			return from.Convert(t, scale)
+		})
 	}
 }
 
-func check(row []string, rid int, prev time.Time, last uint16, delta time.Duration) (time.Time, uint16) {
+func check(row []string, rid int, prev time.Time, last uint16, delta time.Duration, conv func(time.Time) time.Time) (time.Time, uint16) {
 	var (
 		now  = getTime(row[0])
 		curr = getCount(row[2])
@@ -76,8 +88,8 @@ func check(row []string, rid int, prev time.Time, last uint16, delta time.Durati
 		)
 		if timcheck || seqcheck {
 			var (
-				p = prev.UTC()
-				n = now.UTC()
+				p = conv(prev).UTC()
+				n = conv(now).UTC()
 				d = now.Sub(prev)
 			)
 			fmt.Printf(Pattern, rid, p.Format(Format), n.Format(Format), d, last, curr, diff)
@@ -87,6 +99,17 @@ func check(row []string, rid int, prev time.Time, last uint16, delta time.Durati
 	return now, curr
 }
 
+func getScale(field string) mmaconv.TimeScale {
+	var (
+		scale mmaconv.TimeScale
+		ix    = strings.Index(field, "[")
+	)
+	if ix >= 0 {
+		scale.Set(strings.Trim(field[ix:], "[] "))
+	}
+	return scale
+}
+
 func getCount(field string) uint16 {
 	x, _ := strconv.ParseUint(field, 0, 16)
 	return uint16(x)
@@ -6,8 +6,10 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -61,7 +63,7 @@ func (c *Cache) Get(acq time.Time, doy string) (*csv.Writer, error) {
 	if doy != "" {
 		file = fmt.Sprintf("%s.%s", file, doy)
 	}
-	wc, err := Create(filepath.Join(c.dir, file), c.mini, doy=="")
+	wc, err := Create(filepath.Join(c.dir, file), c.mini, doy == "")
 	if err != nil {
 		return nil, err
 	}
@@ -133,23 +135,45 @@ func (w *Writer) Close() error {
 }
 
 type Flag struct {
-	Adjust  bool
-	Iso     bool
-	Flat    bool
-	All     bool
-	Recurse bool
-	Quiet   bool
-	Mini    bool
-	Dir     string
-	Time    time.Duration
-	RecPer  int
+	Adjust    bool
+	Iso       bool
+	Flat      bool
+	All       bool
+	Sigma     bool
+	Recurse   bool
+	Quiet     bool
+	Mini      bool
+	Partial   bool
+	Unique    bool
+	Dir       string
+	Index     string
+	Time      time.Duration
+	RecPer    int
+	Window    int
+	Scale     mmaconv.TimeScale
+	Frame     dump.Frame
+	Unit      dump.Unit
+	Magnitude bool
+	Filter    mmaconv.Filter
+	Saturated dump.Saturation
+	Quality   bool
+	Outside   bool
+	Merge     string
 }
 
 func (f Flag) DumpFlag() dump.Flag {
 	return dump.Flag{
-		Iso:  f.Iso,
-		All:  f.All,
-		Time: f.Time,
+		Iso:       f.Iso,
+		All:       f.All,
+		Sigma:     f.Sigma,
+		Frame:     f.Frame,
+		Unit:      f.Unit,
+		Magnitude: f.Magnitude,
+		Saturated: f.Saturated,
+		Quality:   f.Quality,
+		Source:    f.Merge != "",
+		Time:      f.Time,
+		Scale:     f.Scale,
 	}
 }
 
@@ -157,38 +181,61 @@ const Threshold = 1521
 
 func main() {
 	var (
-		set   Flag
-		sched options.Schedule
-		tbl   = mmaconv.DefaultTable
+		set    Flag
+		sched  options.Schedule
+		tbl    = mmaconv.DefaultTable
+		tables = mmaconv.NewTableSet(mmaconv.DefaultTable)
 	)
 	flag.BoolVar(&set.Adjust, "j", false, "adjust time")
 	flag.BoolVar(&set.Iso, "i", false, "format time as RFC3339")
 	flag.BoolVar(&set.Flat, "f", false, "keep values of same record")
 	flag.BoolVar(&set.All, "a", false, "write all fields")
+	flag.BoolVar(&set.Magnitude, "v", false, "write the magnitude and the horizontal magnitude of the accelerations")
+	flag.BoolVar(&set.Sigma, "n", false, "write the standard uncertainties of the temperatures and accelerations")
 	flag.BoolVar(&set.Mini, "z", false, "compress output file")
 	flag.BoolVar(&set.Recurse, "r", false, "recurse")
+	flag.BoolVar(&set.Quality, "q", false, "write the quality flags of each sample")
+	flag.BoolVar(&set.Outside, "h", false, "write the files outside the periods of activities")
+	flag.BoolVar(&set.Partial, "p", false, "keep records of truncated files")
+	flag.BoolVar(&set.Unique, "u", false, "remove duplicate records across files")
+	flag.StringVar(&set.Index, "k", "", "file where the index of records already seen is kept between runs")
 	flag.DurationVar(&set.Time, "t", 0, "time interval between two records")
 	flag.IntVar(&set.RecPer, "b", Threshold, "max number of records per input files to compute date of each")
+	flag.IntVar(&set.Window, "m", 0, "number of consecutive files used to model the drift of the sample clock")
+	flag.StringVar(&set.Merge, "y", "", "directory of the playback files to merge with the realtime files")
 	flag.StringVar(&set.Dir, "d", "", "diretory where files should be written")
 	flag.Var(&tbl, "c", "parameters table to use")
+	flag.Var(&tables, "e", "parameters tables to use with their validity periods")
+	flag.Var(&set.Unit, "g", "unit of the accelerations in the output (ug, mg, g, m/s2)")
+	flag.Var(&set.Frame, "o", "frame of the accelerations in the output (sensor, station, both)")
+	flag.Var(&set.Filter, "l", "filter of the raw temperatures (none, avg:<size>, lowpass:<alpha>)")
+	flag.Var(&set.Saturated, "w", "samples with saturated raw counts (keep, drop, nan, flag)")
+	flag.Var(&set.Scale, "s", "time scale of the output (gps, utc, tai)")
 	flag.Var(&sched, "x", "range of dates in config files when activities took place")
 	flag.Parse()
 
-	if err := process(tbl, flag.Arg(0), set, sched); err != nil {
+	tables.Default = tbl
+	if err := process(tables, flag.Arg(0), set, sched); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(2)
 	}
 }
 
-func process(tbl mmaconv.Table, dir string, set Flag, sched options.Schedule) error {
+func process(tables mmaconv.TableSet, dir string, set Flag, sched options.Schedule) error {
 	var (
-		headers     = dump.SplitHeaders
+		tbl         = tables.Default
+		base        = set.DumpFlag()
+		headers     []string
 		writeRecord = dump.Split
 		freq        float64
 	)
+	if set.Window > 0 {
+		base.Clock = mmaconv.NewClock(tbl.Frequency, set.Window)
+	}
 	if set.Flat {
 		writeRecord = dump.Flat
-		headers = nil
+	} else {
+		headers = dump.Headers(base)
 	}
 	if set.Adjust {
 		freq = tbl.SampleFrequency()
@@ -196,18 +243,26 @@ func process(tbl mmaconv.Table, dir string, set Flag, sched options.Schedule) er
 	cache := New(set.Dir, set.Mini, headers)
 	defer cache.Close()
 
-	walk.Walk(dir, func(file string, i os.FileInfo, err error) error {
+	opt := mmaconv.Options{
+		Partial:  set.Partial,
+		Timeline: mmaconv.NewTimeline(tbl.Frequency),
+		Scale:    set.Scale,
+		Filter:   &set.Filter,
+		Log:      log.New(os.Stderr, "", 0),
+		Sigma:    set.Sigma,
+	}
+	if set.Unique || set.Index != "" {
+		x, err := mmaconv.LoadIndex(set.Index)
 		if err != nil {
 			return err
 		}
-		if i.IsDir() {
-			if !set.Recurse {
-				err = filepath.SkipDir
-			}
-			return err
-		}
-		ms, err := tbl.Calibrate(file)
-		if err != nil || len(ms) == 0 || !sched.Keep(ms[0].When) {
+		opt.Index = x
+		opt.Mark = set.Quality
+	}
+
+	write := func(file string, ms []mmaconv.Measurement) error {
+		outside := !sched.Keep(ms[0].When)
+		if outside && !set.Outside {
 			return nil
 		}
 
@@ -217,16 +272,196 @@ func process(tbl mmaconv.Table, dir string, set Flag, sched options.Schedule) er
 		}
 		defer ws.Flush()
 
-		df := set.DumpFlag()
+		df := base
+		df.Outside = outside
+		if df.Clock != nil {
+			df.Clock.Update(ms)
+		}
 		if n := len(ms) * mmaconv.MeasCount; set.RecPer > 0 && n >= set.RecPer {
 			df.Indatable = true
 		}
-		_, err = writeRecord(ws, ms, freq, df)
+		if _, err = writeRecord(ws, ms, freq, df); err == nil && opt.Index != nil {
+			opt.Index.AddMeasurements(ms)
+		}
+		return err
+	}
+	if set.Merge != "" {
+		if err := merge(tables, dir, set, opt, write); err != nil {
+			return err
+		}
+	} else {
+		walk.Walk(dir, func(file string, i os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if i.IsDir() {
+				if !set.Recurse {
+					err = filepath.SkipDir
+				}
+				return err
+			}
+			ms, err := tables.CalibrateWith(file, opt)
+			if err != nil && set.Partial {
+				opt.Log.Println(err)
+			}
+			if (err != nil && !set.Partial) || len(ms) == 0 {
+				return nil
+			}
+			return write(file, ms)
+		})
+	}
+	if opt.Index != nil && set.Index != "" {
+		return opt.Index.Save(set.Index)
+	}
+	return nil
+}
+
+type pair struct {
+	Vid      uint32
+	When     time.Time
+	Realtime string
+	Playback string
+}
+
+type origins struct {
+	Both     int
+	Realtime int
+	Playback int
+}
+
+func merge(tables mmaconv.TableSet, dir string, set Flag, opt mmaconv.Options, write func(string, []mmaconv.Measurement) error) error {
+	files, err := pairFiles(dir, set.Merge, set.Recurse, opt.Log)
+	if err != nil {
 		return err
+	}
+	var (
+		report = make(map[time.Time]*origins)
+		days   []time.Time
+	)
+	for _, p := range files {
+		ms, err := tables.CalibrateMerge(p.Realtime, p.Playback, opt)
+		if err != nil && set.Partial {
+			opt.Log.Println(err)
+		}
+		if (err != nil && !set.Partial) || len(ms) == 0 {
+			continue
+		}
+		file := p.Playback
+		if file == "" {
+			file = p.Realtime
+		}
+		if err := write(file, ms); err != nil {
+			return err
+		}
+		for _, m := range ms {
+			day := m.When.Truncate(time.Hour * 24)
+			o, ok := report[day]
+			if !ok {
+				o = &origins{}
+				report[day] = o
+				days = append(days, day)
+			}
+			switch m.Source {
+			case mmaconv.Both:
+				o.Both += mmaconv.MeasCount
+			case mmaconv.Realtime:
+				o.Realtime += mmaconv.MeasCount
+			case mmaconv.Playback:
+				o.Playback += mmaconv.MeasCount
+			}
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
 	})
+	for _, d := range days {
+		o := report[d]
+		fmt.Printf("%s: both: %d, realtime only: %d, playback only: %d\n", d.Format("2006/002"), o.Both, o.Realtime, o.Playback)
+	}
 	return nil
 }
 
+type pairKey struct {
+	Vid  uint32
+	When int64
+}
+
+// pairFiles matches the files of the realtime and playback directories by
+// their vmu sequence counter and acquisition time. When a directory has more
+// than one file with the same key, the collision is logged and each of the
+// other files is converted without being merged.
+func pairFiles(realtime, playback string, recurse bool, logger *log.Logger) ([]pair, error) {
+	var (
+		files = make(map[pairKey]*pair)
+		list  []pair
+	)
+	add := func(dir string, src mmaconv.Source) error {
+		return walk.Walk(dir, func(file string, i os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if i.IsDir() {
+				if !recurse {
+					err = filepath.SkipDir
+				}
+				return err
+			}
+			hdr, err := readHeader(file)
+			if err != nil {
+				return nil
+			}
+			key := pairKey{Vid: hdr.Vid, When: hdr.When.UnixNano()}
+			p, ok := files[key]
+			if !ok {
+				p = &pair{Vid: hdr.Vid, When: hdr.When}
+				files[key] = p
+			}
+			other := &p.Realtime
+			if src == mmaconv.Playback {
+				other = &p.Playback
+			}
+			if *other == "" {
+				*other = file
+				return nil
+			}
+			logger.Printf("%s: same vmu sequence counter (%d) and time as %s: converted without merging", file, hdr.Vid, *other)
+			single := pair{Vid: hdr.Vid, When: hdr.When}
+			if src == mmaconv.Realtime {
+				single.Realtime = file
+			} else {
+				single.Playback = file
+			}
+			list = append(list, single)
+			return nil
+		})
+	}
+	if err := add(realtime, mmaconv.Realtime); err != nil {
+		return nil, err
+	}
+	if err := add(playback, mmaconv.Playback); err != nil {
+		return nil, err
+	}
+	for _, p := range files {
+		list = append(list, *p)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].When.Equal(list[j].When) {
+			return list[i].Vid < list[j].Vid
+		}
+		return list[i].When.Before(list[j].When)
+	})
+	return list, nil
+}
+
+func readHeader(file string) (mmaconv.Header, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return mmaconv.Header{}, err
+	}
+	defer r.Close()
+	return mmaconv.NewDecoder(r).Header()
+}
+
 func doy(file string) string {
 	var (
 		parts = strings.Split(file, "/")
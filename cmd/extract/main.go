@@ -18,6 +18,8 @@ func main() {
 	var (
 		quiet = flag.Bool("q", false, "quiet")
 		nodup = flag.Bool("d", false, "remove duplicate")
+		part  = flag.Bool("p", false, "keep records of truncated files")
+		index = flag.String("k", "", "file where the index of records already seen is kept between runs")
 		sched options.Schedule
 	)
 	flag.Var(&sched, "r", "dates range")
@@ -30,6 +32,22 @@ func main() {
 	ws := csv.NewWriter(out)
 	defer ws.Flush()
 
+	if *index != "" {
+		*nodup = true
+	}
+	opt := mmaconv.Options{
+		Duplicate: !*nodup,
+		Partial:   *part,
+	}
+	if *nodup {
+		x, err := mmaconv.LoadIndex(*index)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		opt.Index = x
+	}
+
 	var (
 		str  = make([]string, 2, 32)
 		prev uint16
@@ -40,8 +58,11 @@ func main() {
 			return err
 		}
 
-		data, err := mmaconv.Convert(file, !*nodup)
-		if err != nil || len(data) == 0 {
+		data, err := mmaconv.ConvertWith(file, opt)
+		if err != nil && *part {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if (err != nil && !*part) || len(data) == 0 {
 			return nil
 		}
 		if !sched.Keep(data[0].When) {
@@ -58,15 +79,26 @@ func main() {
 			for i := range rec.Raw[1:] {
 				str = append(str, strconv.FormatInt(int64(rec.Raw[i+1]), 10))
 			}
+			str = append(str, rec.Status.String(), rec.Status.Bits())
 			if err := ws.Write(str); err != nil {
 				return err
 			}
 			str = str[:2]
 			msg++
+			if opt.Index != nil {
+				opt.Index.Add(rec)
+			}
 		}
 		return nil
 	})
 	ws.Flush()
 	fmt.Printf("messages: %d", msg)
 	fmt.Println()
+
+	if opt.Index != nil && *index != "" {
+		if err := opt.Index.Save(*index); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
 }
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/busoc/mmaconv"
+	"github.com/busoc/mmaconv/cmd/internal/walk"
+)
+
+const Pattern = "%s-axis: temperature rms: %8.4f degC, acceleration rms: %10.4f microG, r2: %.6f (records: %d, temperature bins: %d)"
+
+const (
+	// degree of the polynomials of the offset (B1..B4) and scale factor (C1..C4)
+	Degree = 4
+	// minimum number of temperature bins needed to fit the polynomials with
+	// some degrees of freedom left for the residuals
+	MinBins = Degree + 2
+)
+
+type key struct {
+	Vid uint32
+	Seq uint16
+}
+
+// Reference holds the temperatures (degree celsius) and accelerations (micro g)
+// measured on ground for a record.
+type Reference struct {
+	Deg [3]float64
+	Acc [3]float64
+}
+
+type Sample struct {
+	Temp  int16
+	Deg   float64
+	Count float64
+	Acc   float64
+}
+
+func main() {
+	var (
+		tbl   = mmaconv.DefaultTable
+		file  = flag.String("o", "", "file where the fitted table should be written")
+		width = flag.Float64("w", 0.5, "width (degree celsius) of the temperature bins")
+	)
+	flag.Var(&tbl, "c", "parameters table used as starting point")
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "usage: mmafit [-c table] [-o file] [-w width] <reference.csv> <file|dir...>")
+		os.Exit(1)
+	}
+	refs, err := readReferences(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	samples, err := collect(flag.Args()[1:], refs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *width <= 0 {
+		fmt.Fprintln(os.Stderr, "width of the temperature bins should be greater than 0")
+		os.Exit(1)
+	}
+	if err := fit(&tbl, samples, *width); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var w io.Writer = os.Stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if _, err := tbl.WriteTo(w); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// readReferences reads a csv file whose rows have the following columns:
+// vmu-sequence, sequence, Tx, Ty, Tz, Ax, Ay, Az. Rows that do not start with
+// a number (eg: headers) are skipped.
+func readReferences(file string) (map[key]Reference, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var (
+		rs   = csv.NewReader(r)
+		refs = make(map[key]Reference)
+	)
+	rs.Comment = '#'
+	rs.FieldsPerRecord = 8
+	for {
+		row, err := rs.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		vid, err := strconv.ParseUint(row[0], 10, 32)
+		if err != nil {
+			continue
+		}
+		seq, err := strconv.ParseUint(row[1], 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		var ref Reference
+		for i := 0; i < 3; i++ {
+			if ref.Deg[i], err = strconv.ParseFloat(row[2+i], 64); err != nil {
+				return nil, err
+			}
+			if ref.Acc[i], err = strconv.ParseFloat(row[5+i], 64); err != nil {
+				return nil, err
+			}
+		}
+		refs[key{Vid: uint32(vid), Seq: uint16(seq)}] = ref
+	}
+	return refs, nil
+}
+
+func collect(dirs []string, refs map[key]Reference) ([3][]Sample, error) {
+	var samples [3][]Sample
+	for _, d := range dirs {
+		err := walk.Walk(d, func(file string, i os.FileInfo, err error) error {
+			if err != nil || i.IsDir() {
+				return err
+			}
+			rs, err := mmaconv.Convert(file, false)
+			if err != nil {
+				return nil
+			}
+			for _, r := range rs {
+				ref, ok := refs[key{Vid: r.Vid, Seq: r.Seq}]
+				if !ok {
+					continue
+				}
+				for j := 0; j < 3; j++ {
+					for k := 4 + j; k < len(r.Raw); k += 3 {
+						s := Sample{
+							Temp:  r.Raw[j],
+							Deg:   ref.Deg[j],
+							Count: float64(r.Raw[k]),
+							Acc:   ref.Acc[j],
+						}
+						samples[j] = append(samples[j], s)
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return samples, err
+		}
+	}
+	return samples, nil
+}
+
+func fit(tbl *mmaconv.Table, samples [3][]Sample, width float64) error {
+	var (
+		ref, zero = tbl.Reference()
+		axes      = []struct {
+			Name   string
+			ABC    *mmaconv.ABC
+			Ref    float64
+			Zero   float64
+			Scale  *float64
+			Offset *float64
+		}{
+			{Name: "x", ABC: &tbl.AxisX, Ref: ref.X, Zero: zero.X, Scale: &tbl.Scale.X, Offset: &tbl.Offset.X},
+			{Name: "y", ABC: &tbl.AxisY, Ref: ref.Y, Zero: zero.Y, Scale: &tbl.Scale.Y, Offset: &tbl.Offset.Y},
+			{Name: "z", ABC: &tbl.AxisZ, Ref: ref.Z, Zero: zero.Z, Scale: &tbl.Scale.Z, Offset: &tbl.Offset.Z},
+		}
+	)
+	for i, a := range axes {
+		ss := samples[i]
+		if len(ss) == 0 {
+			return fmt.Errorf("%s-axis: no records matching the reference data", a.Name)
+		}
+		if err := fitTemperature(a.ABC, ss, a.Ref+a.Zero); err != nil {
+			return fmt.Errorf("%s-axis: %w", a.Name, err)
+		}
+		bins, err := fitAcceleration(a.ABC, a.Scale, a.Offset, ss, a.Ref, width)
+		if err != nil {
+			return fmt.Errorf("%s-axis: %w", a.Name, err)
+		}
+
+		var (
+			degrms, accrms, r2 = residuals(*a.ABC, *a.Scale, *a.Offset, ss, a.Ref, a.Zero)
+			records            = len(ss) / mmaconv.MeasCount
+		)
+		fmt.Fprintf(os.Stderr, Pattern, a.Name, degrms, accrms, r2, records, bins)
+		fmt.Fprintln(os.Stderr)
+	}
+	return nil
+}
+
+// fitTemperature fits A0 and A1 from the raw temperature words and the
+// reference temperatures.
+func fitTemperature(abc *mmaconv.ABC, ss []Sample, ref float64) error {
+	var xs, ys []float64
+	for _, s := range ss {
+		mica, _ := abc.TemperaturesAt(float64(s.Temp), ref)
+		xs = append(xs, s.Deg-ref)
+		ys = append(ys, mica)
+	}
+	cs, err := polyfit(xs, ys, 1)
+	if err != nil {
+		return err
+	}
+	abc.A0, abc.A1 = cs[0], cs[1]
+	return nil
+}
+
+type bin struct {
+	ai     float64
+	counts []float64
+	accs   []float64
+}
+
+// fitAcceleration groups the samples in bins of the given width (degree
+// celsius, the same as micro ampere) of their temperature and computes for
+// each bin the scale factor and the offset between the raw counts and the
+// reference accelerations. The B1..B4 and C1..C4 coefficients are then fitted
+// over these values. Bins with a single reference acceleration are skipped.
+// It returns the number of bins used.
+func fitAcceleration(abc *mmaconv.ABC, scale, offset *float64, ss []Sample, ref, width float64) (int, error) {
+	var (
+		groups = make(map[int64]*bin)
+		keys   []int64
+	)
+	for _, s := range ss {
+		var (
+			mica, _ = abc.TemperaturesAt(float64(s.Temp), 0)
+			ai      = mica - ref
+			k       = int64(math.Floor(ai / width))
+		)
+		b, ok := groups[k]
+		if !ok {
+			b = &bin{}
+			groups[k] = b
+			keys = append(keys, k)
+		}
+		b.ai += ai
+		b.counts = append(b.counts, s.Count)
+		b.accs = append(b.accs, s.Acc)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	var ais, invs, offs []float64
+	for _, k := range keys {
+		b := groups[k]
+		if !distinct(b.accs) {
+			continue
+		}
+		cs, err := polyfit(b.counts, b.accs, 1)
+		if err != nil || cs[1] == 0 {
+			continue
+		}
+		ais = append(ais, b.ai/float64(len(b.counts)))
+		invs = append(invs, 1/cs[1])
+		offs = append(offs, -cs[0])
+	}
+	if len(ais) < MinBins {
+		return len(ais), fmt.Errorf("%d temperature bins with distinct accelerations, at least %d needed", len(ais), MinBins)
+	}
+	bs, err := polyfit(ais, offs, Degree)
+	if err != nil {
+		return len(ais), err
+	}
+	ds, err := polyfit(ais, invs, Degree)
+	if err != nil {
+		return len(ais), err
+	}
+	if ds[0] == 0 {
+		return len(ais), fmt.Errorf("scale factor can not be computed")
+	}
+
+	*offset = bs[0]
+	abc.B1, abc.B2, abc.B3, abc.B4 = bs[1], bs[2], bs[3], bs[4]
+
+	c0 := abc.C0
+	if c0 == 0 {
+		c0 = 1
+	}
+	*scale = 1 / ds[0]
+	abc.C0 = c0
+	abc.C1 = c0 * ds[1] / ds[0]
+	abc.C2 = c0 * ds[2] / ds[0]
+	abc.C3 = c0 * ds[3] / ds[0]
+	abc.C4 = c0 * ds[4] / ds[0]
+	return len(ais), nil
+}
+
+func distinct(vs []float64) bool {
+	for _, v := range vs[1:] {
+		if v != vs[0] {
+			return true
+		}
+	}
+	return false
+}
+
+func residuals(abc mmaconv.ABC, scale, offset float64, ss []Sample, ref, zero float64) (float64, float64, float64) {
+	var (
+		degsum float64
+		accsum float64
+		mean   float64
+		total  float64
+	)
+	for _, s := range ss {
+		mean += s.Acc
+	}
+	mean /= float64(len(ss))
+	for _, s := range ss {
+		mica, deg := abc.TemperaturesAt(float64(s.Temp), ref+zero)
+		var (
+			ai  = mica - ref
+			acc = (s.Count * abc.ScaleFactor(scale, ai)) - abc.TempOffset(offset, ai)
+		)
+		degsum += math.Pow(deg-s.Deg, 2)
+		accsum += math.Pow(acc-s.Acc, 2)
+		total += math.Pow(s.Acc-mean, 2)
+	}
+	n := float64(len(ss))
+	r2 := 1.0
+	if total > 0 {
+		r2 = 1 - (accsum / total)
+	}
+	return math.Sqrt(degsum / n), math.Sqrt(accsum / n), r2
+}
+
+var errSingular = errors.New("singular system")
+
+// polyfit computes the coefficients (from the constant term) of the polynomial
+// of the given degree fitting best (least squares) the given points.
+func polyfit(xs, ys []float64, deg int) ([]float64, error) {
+	var (
+		size = deg + 1
+		mat  = make([][]float64, size)
+	)
+	if len(xs) < size {
+		return nil, fmt.Errorf("not enough points (%d) for a polynomial of degree %d", len(xs), deg)
+	}
+	for i := range mat {
+		mat[i] = make([]float64, size+1)
+	}
+	for k := range xs {
+		for i := 0; i < size; i++ {
+			xi := math.Pow(xs[k], float64(i))
+			for j := 0; j < size; j++ {
+				mat[i][j] += xi * math.Pow(xs[k], float64(j))
+			}
+			mat[i][size] += xi * ys[k]
+		}
+	}
+	for i := 0; i < size; i++ {
+		p := i
+		for j := i + 1; j < size; j++ {
+			if math.Abs(mat[j][i]) > math.Abs(mat[p][i]) {
+				p = j
+			}
+		}
+		if mat[p][i] == 0 {
+			return nil, errSingular
+		}
+		mat[i], mat[p] = mat[p], mat[i]
+		for j := i + 1; j < size; j++ {
+			f := mat[j][i] / mat[i][i]
+			for k := i; k <= size; k++ {
+				mat[j][k] -= f * mat[i][k]
+			}
+		}
+	}
+	cs := make([]float64, size)
+	for i := size - 1; i >= 0; i-- {
+		v := mat[i][size]
+		for j := i + 1; j < size; j++ {
+			v -= mat[i][j] * cs[j]
+		}
+		cs[i] = v / mat[i][i]
+	}
+	return cs, nil
+}
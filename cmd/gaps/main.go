@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/busoc/mmaconv"
+	"github.com/busoc/mmaconv/cmd/internal/walk"
+)
+
+const (
+	Pattern    = "%-8s vmu: %10d -> %10d (missing: %6d) %s -> %s (%s), samples lost: %d"
+	TimeFormat = "2006-01-02 15:04:05.000"
+)
+
+func main() {
+	var (
+		freq    = flag.Int64("f", mmaconv.DefaultTable.Frequency, "frequency (Hz) of the acquisition")
+		csvfmt  = flag.Bool("c", false, "write the gaps as csv")
+		verbose = flag.Bool("v", false, "write the files of each gap")
+	)
+	flag.Parse()
+
+	var (
+		cont = mmaconv.NewContinuity(*freq)
+		ws   = csv.NewWriter(os.Stdout)
+	)
+	if *csvfmt {
+		ws.Write([]string{
+			"kind",
+			"previous-file",
+			"file",
+			"previous-vmu",
+			"vmu",
+			"missing",
+			"starts",
+			"ends",
+			"duration [s]",
+			"samples",
+		})
+	}
+	for _, a := range flag.Args() {
+		err := walk.Walk(a, func(file string, i os.FileInfo, err error) error {
+			if err != nil || i.IsDir() {
+				return err
+			}
+			g, ok, err := cont.AddFile(file)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				if !errors.Is(err, mmaconv.ErrRecord) {
+					return nil
+				}
+			}
+			if !ok {
+				return nil
+			}
+			if *csvfmt {
+				return writeGap(ws, g)
+			}
+			printGap(g, *verbose)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	ws.Flush()
+	if err := ws.Error(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
+
+func printGap(g mmaconv.Gap, verbose bool) {
+	fmt.Printf(Pattern, g.Kind, g.Prev, g.Curr, g.Missing, g.Starts.Format(TimeFormat), g.Ends.Format(TimeFormat), g.Duration(), g.Samples)
+	fmt.Println()
+	if verbose {
+		fmt.Printf("  %s", g.PrevFile)
+		fmt.Println()
+		fmt.Printf("  %s", g.File)
+		fmt.Println()
+	}
+}
+
+func writeGap(ws *csv.Writer, g mmaconv.Gap) error {
+	row := []string{
+		g.Kind.String(),
+		g.PrevFile,
+		g.File,
+		strconv.FormatUint(uint64(g.Prev), 10),
+		strconv.FormatUint(uint64(g.Curr), 10),
+		strconv.FormatUint(uint64(g.Missing), 10),
+		g.Starts.Format(time.RFC3339Nano),
+		g.Ends.Format(time.RFC3339Nano),
+		strconv.FormatFloat(g.Duration().Seconds(), 'f', -1, 64),
+		strconv.FormatInt(g.Samples, 10),
+	}
+	return ws.Write(row)
+}
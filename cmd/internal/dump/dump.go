@@ -2,6 +2,8 @@ package dump
 
 import (
 	"encoding/csv"
+	"fmt"
+	"math"
 	"strconv"
 	"time"
 
@@ -26,14 +28,90 @@ const (
 	isoFormat       = "2006-01-02T15:04:05.000000"
 	splitFieldCount = 10
 	flatFieldCount  = (3 * mmaconv.MeasCount) + 7
-	allFieldDiff    = 9
+	allFieldDiff    = 19
+	sigmaFieldCount = 6
 )
 
+var AllHeaders = []string{
+	"Ix [microA]",
+	"Iy [microA]",
+	"Iz [microA]",
+	"scale x",
+	"offset x",
+	"scale y",
+	"offset y",
+	"scale z",
+	"offset z",
+	"status",
+	"status bits",
+	"count",
+	"reason",
+	"Rx [K]",
+	"Ry [K]",
+	"Rz [K]",
+	"Tx unfiltered [degC]",
+	"Ty unfiltered [degC]",
+	"Tz unfiltered [degC]",
+}
+
+var SigmaHeaders = []string{
+	"sigma Tx [degC]",
+	"sigma Ty [degC]",
+	"sigma Tz [degC]",
+	"sigma Ax [microG]",
+	"sigma Ay [microG]",
+	"sigma Az [microG]",
+}
+
 type Flag struct {
 	Indatable bool
 	Iso       bool
 	All       bool
+	Sigma     bool
+	Frame     Frame
+	Unit      Unit
+	Magnitude bool
+	Saturated Saturation
+	Quality   bool
+	Source    bool
+	Outside   bool
 	Time      time.Duration
+	Clock     *mmaconv.Clock
+	Scale     mmaconv.TimeScale
+}
+
+func Headers(set Flag) []string {
+	hs := make([]string, len(SplitHeaders))
+	copy(hs, SplitHeaders)
+	hs[0] = fmt.Sprintf("%s [%s]", hs[0], set.Scale)
+	switch set.Frame {
+	case Station:
+		copy(hs[7:], StationHeaders)
+	case Both:
+		hs = append(hs, StationHeaders...)
+	}
+	if set.All {
+		hs = append(hs[:7], append(AllHeaders, hs[7:]...)...)
+	}
+	if set.Magnitude {
+		hs = append(hs, MagnitudeHeaders...)
+	}
+	if set.Saturated == FlagSaturated {
+		hs = append(hs, "saturated")
+	}
+	if set.Quality {
+		hs = append(hs, "quality")
+	}
+	if set.Source {
+		hs = append(hs, "source")
+	}
+	if set.Sigma {
+		hs = append(hs, SigmaHeaders...)
+	}
+	if set.Clock != nil {
+		hs = append(hs, "residual [s]")
+	}
+	return withUnit(hs, set.Unit)
 }
 
 func Split(ws *csv.Writer, data []mmaconv.Measurement, freq float64, set Flag) (time.Time, error) {
@@ -48,6 +126,27 @@ func Split(ws *csv.Writer, data []mmaconv.Measurement, freq float64, set Flag) (
 	if set.All {
 		size += allFieldDiff
 	}
+	if set.Frame == Both {
+		size += 3
+	}
+	if set.Magnitude {
+		size += 2
+	}
+	if set.Saturated == FlagSaturated {
+		size++
+	}
+	if set.Quality {
+		size++
+	}
+	if set.Source {
+		size++
+	}
+	if set.Sigma {
+		size += sigmaFieldCount
+	}
+	if set.Clock != nil {
+		size++
+	}
 	if set.Iso {
 		tf = isoFormat
 	}
@@ -56,6 +155,7 @@ func Split(ws *csv.Writer, data []mmaconv.Measurement, freq float64, set Flag) (
 		delta   = time.Duration(freq*1_000_000_000) * time.Nanosecond
 		prev    uint16
 		elapsed time.Duration
+		first   = firstCount(data)
 	)
 	if set.Time > 0 {
 		delta = set.Time
@@ -65,11 +165,24 @@ func Split(ws *csv.Writer, data []mmaconv.Measurement, freq float64, set Flag) (
 		if d := sequenceDelta(i, curr, prev); elapsed > 0 && d > 0 && d != mmaconv.MeasCount {
 			elapsed += delta * time.Duration(d/mmaconv.MeasCount)
 		}
+		if set.Saturated == NaNSaturated {
+			m = withNaN(m)
+		}
 		for i := 0; i < mmaconv.MeasCount; i++ {
+			if set.Saturated == DropSaturated && m.IsSaturated(i) {
+				if !m.NoDate {
+					elapsed += delta
+				}
+				continue
+			}
 			if m.NoDate || set.Indatable {
 				str = append(str, "")
 			} else {
-				now = m.When.Add(elapsed)
+				if set.Clock != nil {
+					now = set.Clock.Sample(m.Count, i)
+				} else {
+					now = m.When.Add(elapsed)
+				}
 				str = append(str, now.Format(tf))
 			}
 			str = append(str, m.UPI)
@@ -81,9 +194,25 @@ func Split(ws *csv.Writer, data []mmaconv.Measurement, freq float64, set Flag) (
 			if set.All {
 				str = appendFields(str, m)
 			}
-			str = append(str, formatFloat(m.AccX[i]))
-			str = append(str, formatFloat(m.AccY[i]))
-			str = append(str, formatFloat(m.AccZ[i]))
+			str = appendAccelerations(str, m, i, set)
+			if set.Saturated == FlagSaturated {
+				str = append(str, formatSaturation(m, i))
+			}
+			if set.Quality {
+				str = append(str, formatQuality(m, i, set))
+			}
+			if set.Source {
+				str = append(str, m.Source.String())
+			}
+			if set.Sigma {
+				str = appendSigmas(str, m)
+				str = append(str, formatFloat(set.Unit.Convert(sigmaAt(m.SigmaAccX, i))))
+				str = append(str, formatFloat(set.Unit.Convert(sigmaAt(m.SigmaAccY, i))))
+				str = append(str, formatFloat(set.Unit.Convert(sigmaAt(m.SigmaAccZ, i))))
+			}
+			if set.Clock != nil {
+				str = append(str, formatFloat(set.Clock.Residual(m.When, first, m.Count, i).Seconds()))
+			}
 			if err := ws.Write(str); err != nil {
 				return now, err
 			}
@@ -110,6 +239,27 @@ func Flat(ws *csv.Writer, data []mmaconv.Measurement, freq float64, set Flag) (t
 	if set.All {
 		size += allFieldDiff
 	}
+	if set.Frame == Both {
+		size += 3 * mmaconv.MeasCount
+	}
+	if set.Magnitude {
+		size += 2 * mmaconv.MeasCount
+	}
+	if set.Saturated == FlagSaturated {
+		size += mmaconv.MeasCount
+	}
+	if set.Quality {
+		size += mmaconv.MeasCount
+	}
+	if set.Source {
+		size++
+	}
+	if set.Sigma {
+		size += 3 + (3 * mmaconv.MeasCount)
+	}
+	if set.Clock != nil {
+		size++
+	}
 	if set.Iso {
 		tf = isoFormat
 	}
@@ -118,6 +268,7 @@ func Flat(ws *csv.Writer, data []mmaconv.Measurement, freq float64, set Flag) (t
 		delta   = time.Duration(freq*1_000_000) * time.Microsecond
 		prev    uint16
 		elapsed time.Duration
+		first   = firstCount(data)
 	)
 	if set.Time > 0 {
 		delta = set.Time
@@ -127,10 +278,24 @@ func Flat(ws *csv.Writer, data []mmaconv.Measurement, freq float64, set Flag) (t
 		if d := sequenceDelta(i, curr, prev); elapsed > 0 && d > 0 && d != mmaconv.MeasCount {
 			elapsed += delta * time.Duration(d/mmaconv.MeasCount)
 		}
+		if set.Saturated == DropSaturated && m.Saturated() > 0 {
+			if !m.NoDate {
+				elapsed += mmaconv.MeasCount * delta
+			}
+			prev = curr
+			continue
+		}
+		if set.Saturated == NaNSaturated {
+			m = withNaN(m)
+		}
 		if m.NoDate || set.Indatable {
 			str = append(str, "")
 		} else {
-			now = m.When.Add(elapsed)
+			if set.Clock != nil {
+				now = set.Clock.Time(m.Count)
+			} else {
+				now = m.When.Add(elapsed)
+			}
 			str = append(str, now.Format(tf))
 		}
 		str = append(str, m.UPI)
@@ -143,9 +308,31 @@ func Flat(ws *csv.Writer, data []mmaconv.Measurement, freq float64, set Flag) (t
 			str = appendFields(str, m)
 		}
 		for i := 0; i < mmaconv.MeasCount; i++ {
-			str = append(str, formatFloat(m.AccX[i]))
-			str = append(str, formatFloat(m.AccY[i]))
-			str = append(str, formatFloat(m.AccZ[i]))
+			str = appendAccelerations(str, m, i, set)
+		}
+		if set.Saturated == FlagSaturated {
+			for i := 0; i < mmaconv.MeasCount; i++ {
+				str = append(str, formatSaturation(m, i))
+			}
+		}
+		if set.Quality {
+			for i := 0; i < mmaconv.MeasCount; i++ {
+				str = append(str, formatQuality(m, i, set))
+			}
+		}
+		if set.Source {
+			str = append(str, m.Source.String())
+		}
+		if set.Sigma {
+			str = appendSigmas(str, m)
+			for i := 0; i < mmaconv.MeasCount; i++ {
+				str = append(str, formatFloat(set.Unit.Convert(sigmaAt(m.SigmaAccX, i))))
+				str = append(str, formatFloat(set.Unit.Convert(sigmaAt(m.SigmaAccY, i))))
+				str = append(str, formatFloat(set.Unit.Convert(sigmaAt(m.SigmaAccZ, i))))
+			}
+		}
+		if set.Clock != nil {
+			str = append(str, formatFloat(set.Clock.Residual(m.When, first, m.Count, 0).Seconds()))
 		}
 		if err := ws.Write(str); err != nil {
 			return now, err
@@ -170,9 +357,57 @@ func appendFields(str []string, m mmaconv.Measurement) []string {
 	str = append(str, formatFloat(m.OffsetY))
 	str = append(str, formatFloat(m.ScaleZ))
 	str = append(str, formatFloat(m.OffsetZ))
+	str = append(str, m.Status.String())
+	str = append(str, m.Status.Bits())
+	str = append(str, strconv.FormatInt(m.Count, 10))
+	str = append(str, m.Reason.String())
+	str = append(str, formatFloat(m.RefX))
+	str = append(str, formatFloat(m.RefY))
+	str = append(str, formatFloat(m.RefZ))
+	str = append(str, formatFloat(m.RawDegX))
+	str = append(str, formatFloat(m.RawDegY))
+	str = append(str, formatFloat(m.RawDegZ))
+	return str
+}
+
+// firstCount gives the unwrapped counter of the first dated record, the one
+// whose time is given by the acquisition time of the file.
+func firstCount(data []mmaconv.Measurement) int64 {
+	for _, m := range data {
+		if !m.NoDate {
+			return m.Count
+		}
+	}
+	return data[0].Count
+}
+
+// sigmaAt gives the uncertainty of the ith sample or NaN if the model of the
+// table does not give it.
+func sigmaAt(vs []float64, i int) float64 {
+	if i >= len(vs) {
+		return math.NaN()
+	}
+	return vs[i]
+}
+
+func appendSigmas(str []string, m mmaconv.Measurement) []string {
+	str = append(str, formatFloat(m.SigmaDegX))
+	str = append(str, formatFloat(m.SigmaDegY))
+	str = append(str, formatFloat(m.SigmaDegZ))
 	return str
 }
 
+func formatQuality(m mmaconv.Measurement, i int, set Flag) string {
+	q := m.Quality(i)
+	if set.Indatable {
+		q |= mmaconv.QualityIndatable
+	}
+	if set.Outside {
+		q |= mmaconv.QualitySchedule
+	}
+	return strconv.FormatUint(uint64(q), 10)
+}
+
 func formatFloat(v float64) string {
 	return strconv.FormatFloat(v, 'f', -1, 64)
 }
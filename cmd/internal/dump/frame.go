@@ -0,0 +1,73 @@
+package dump
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/busoc/mmaconv"
+)
+
+// Frame selects the frame(s) of the accelerations written in the output.
+type Frame uint8
+
+const (
+	Sensor Frame = iota
+	Station
+	Both
+)
+
+var StationHeaders = []string{
+	"Ax station [microG]",
+	"Ay station [microG]",
+	"Az station [microG]",
+}
+
+func (f *Frame) Set(str string) error {
+	switch strings.ToLower(str) {
+	case "sensor", "":
+		*f = Sensor
+	case "station":
+		*f = Station
+	case "both":
+		*f = Both
+	default:
+		return fmt.Errorf("%s: unknown frame", str)
+	}
+	return nil
+}
+
+func (f Frame) String() string {
+	switch f {
+	case Sensor:
+		return "sensor"
+	case Station:
+		return "station"
+	case Both:
+		return "both"
+	default:
+		return "unknown"
+	}
+}
+
+func appendAccelerations(str []string, m mmaconv.Measurement, i int, set Flag) []string {
+	if set.Frame == Sensor || set.Frame == Both {
+		str = append(str, formatFloat(set.Unit.Convert(m.AccX[i])))
+		str = append(str, formatFloat(set.Unit.Convert(m.AccY[i])))
+		str = append(str, formatFloat(set.Unit.Convert(m.AccZ[i])))
+	}
+	x, y, z := m.AccX, m.AccY, m.AccZ
+	if set.Frame != Sensor && len(m.BodyX) > 0 {
+		x, y, z = m.BodyX, m.BodyY, m.BodyZ
+	}
+	if set.Frame == Station || set.Frame == Both {
+		str = append(str, formatFloat(set.Unit.Convert(x[i])))
+		str = append(str, formatFloat(set.Unit.Convert(y[i])))
+		str = append(str, formatFloat(set.Unit.Convert(z[i])))
+	}
+	if set.Magnitude {
+		mag, hor := magnitudes(x[i], y[i], z[i])
+		str = append(str, formatFloat(set.Unit.Convert(mag)))
+		str = append(str, formatFloat(set.Unit.Convert(hor)))
+	}
+	return str
+}
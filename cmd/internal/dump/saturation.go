@@ -0,0 +1,98 @@
+package dump
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/busoc/mmaconv"
+)
+
+// Saturation selects how the samples with saturated raw counts are written.
+type Saturation uint8
+
+const (
+	KeepSaturated Saturation = iota
+	DropSaturated
+	NaNSaturated
+	FlagSaturated
+)
+
+func (s *Saturation) Set(str string) error {
+	switch strings.ToLower(str) {
+	case "keep", "":
+		*s = KeepSaturated
+	case "drop":
+		*s = DropSaturated
+	case "nan":
+		*s = NaNSaturated
+	case "flag":
+		*s = FlagSaturated
+	default:
+		return fmt.Errorf("%s: unknown saturation mode", str)
+	}
+	return nil
+}
+
+func (s Saturation) String() string {
+	switch s {
+	case KeepSaturated:
+		return "keep"
+	case DropSaturated:
+		return "drop"
+	case NaNSaturated:
+		return "nan"
+	case FlagSaturated:
+		return "flag"
+	default:
+		return "unknown"
+	}
+}
+
+// withNaN replaces the saturated accelerations of a measurement by NaN. All the
+// axes of the station frame are replaced since each of them depends on all the
+// axes of the sensor.
+func withNaN(m mmaconv.Measurement) mmaconv.Measurement {
+	var (
+		x  = append([]float64{}, m.AccX...)
+		y  = append([]float64{}, m.AccY...)
+		z  = append([]float64{}, m.AccZ...)
+		bx = append([]float64{}, m.BodyX...)
+		by = append([]float64{}, m.BodyY...)
+		bz = append([]float64{}, m.BodyZ...)
+	)
+	for i := range x {
+		if !m.IsSaturated(i) {
+			continue
+		}
+		if m.SatX[i] {
+			x[i] = math.NaN()
+		}
+		if m.SatY[i] {
+			y[i] = math.NaN()
+		}
+		if m.SatZ[i] {
+			z[i] = math.NaN()
+		}
+		if i < len(bx) {
+			bx[i], by[i], bz[i] = math.NaN(), math.NaN(), math.NaN()
+		}
+	}
+	m.AccX, m.AccY, m.AccZ = x, y, z
+	m.BodyX, m.BodyY, m.BodyZ = bx, by, bz
+	return m
+}
+
+func formatSaturation(m mmaconv.Measurement, i int) string {
+	var str []string
+	if i < len(m.SatX) && m.SatX[i] {
+		str = append(str, "x")
+	}
+	if i < len(m.SatY) && m.SatY[i] {
+		str = append(str, "y")
+	}
+	if i < len(m.SatZ) && m.SatZ[i] {
+		str = append(str, "z")
+	}
+	return strings.Join(str, "|")
+}
@@ -0,0 +1,85 @@
+package dump
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/busoc/mmaconv"
+)
+
+// Unit selects the unit of the accelerations written in the output.
+type Unit uint8
+
+const (
+	MicroG Unit = iota
+	MilliG
+	G
+	MeterPerSecond2
+)
+
+var MagnitudeHeaders = []string{
+	"|A| [microG]",
+	"|Axy| [microG]",
+}
+
+func (u *Unit) Set(str string) error {
+	switch strings.ToLower(str) {
+	case "microg", "ug", "µg", "":
+		*u = MicroG
+	case "millig", "mg":
+		*u = MilliG
+	case "g":
+		*u = G
+	case "m/s2", "m/s²":
+		*u = MeterPerSecond2
+	default:
+		return fmt.Errorf("%s: unknown unit", str)
+	}
+	return nil
+}
+
+func (u Unit) String() string {
+	switch u {
+	case MicroG:
+		return "microG"
+	case MilliG:
+		return "mG"
+	case G:
+		return "g"
+	case MeterPerSecond2:
+		return "m/s2"
+	default:
+		return "unknown"
+	}
+}
+
+// Convert converts an acceleration given in micro g into the unit.
+func (u Unit) Convert(v float64) float64 {
+	switch u {
+	case MilliG:
+		return v / 1e3
+	case G:
+		return v / 1e6
+	case MeterPerSecond2:
+		return v / 1e6 * mmaconv.StandardGravity
+	default:
+		return v
+	}
+}
+
+func withUnit(headers []string, u Unit) []string {
+	if u == MicroG {
+		return headers
+	}
+	for i, h := range headers {
+		headers[i] = strings.Replace(h, "[microG]", "["+u.String()+"]", 1)
+	}
+	return headers
+}
+
+// magnitudes gives the magnitude of the acceleration vector and of its
+// projection on the XY plane.
+func magnitudes(x, y, z float64) (float64, float64) {
+	return math.Sqrt((x * x) + (y * y) + (z * z)), math.Hypot(x, y)
+}
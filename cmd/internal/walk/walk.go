@@ -4,9 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
-	"strings"
 	"time"
+
+	"github.com/busoc/mmaconv"
 )
 
 func Walk(root string, fn filepath.WalkFunc) error {
@@ -85,17 +85,10 @@ func readDirNames(dir string) ([]string, error) {
 	return names, nil
 }
 
-const timePattern = "20060102_150405"
-
 func splitFile(file string) (int, time.Time) {
-	var (
-		ps = strings.Split(file, "_")
-		z  = len(ps)
-	)
-	if z <= 1 {
+	fn, err := mmaconv.ParseFilename(file)
+	if err != nil {
 		return 0, time.Time{}
 	}
-	when, _ := time.Parse(timePattern, strings.Join(ps[z-3:z-1], "_"))
-	seq, _ := strconv.ParseInt(ps[z-4], 10, 64)
-	return int(seq), when
+	return fn.Sequence, fn.When
 }
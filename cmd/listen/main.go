@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"os"
 	"path/filepath"
@@ -26,27 +27,49 @@ type Option struct {
 	AdjustTime bool   `toml:"adjust-time"`
 	IsoTime    bool   `toml:"iso-format"`
 	Compress   bool
+	Sigma      bool
+	Frame      dump.Frame
+	Unit       dump.Unit
+	Magnitude  bool
+	Quality    bool
 	Interval   string
+	Scale      mmaconv.TimeScale `toml:"time-scale"`
+	Tables     mmaconv.TableSet  `toml:"calibration"`
+	Filter     mmaconv.Filter    `toml:"temperature-filter"`
+
+	filter *mmaconv.Filter
+	logger *log.Logger
 }
 
 func (o Option) DumpFlag() dump.Flag {
 	dur, _ := time.ParseDuration(o.Interval)
 	return dump.Flag{
-		Iso:  o.IsoTime,
-		All:  false,
-		Time: dur,
+		Iso:       o.IsoTime,
+		All:       false,
+		Sigma:     o.Sigma,
+		Frame:     o.Frame,
+		Unit:      o.Unit,
+		Magnitude: o.Magnitude,
+		Quality:   o.Quality,
+		Time:      dur,
+		Scale:     o.Scale,
 	}
 }
 
 func main() {
 	flag.Parse()
 
-	var opt Option
+	opt := Option{
+		Tables: mmaconv.NewTableSet(mmaconv.DefaultTable),
+	}
 	if err := toml.DecodeFile(flag.Arg(0), &opt); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
+	opt.filter = &opt.Filter
+	opt.logger = log.New(os.Stderr, "", 0)
+
 	queue, err := Listen(opt.Addr)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -54,7 +77,7 @@ func main() {
 	}
 	for m := range queue {
 		if err := Process(m, opt); err != nil {
-			fmt.Fprintf(os.Stderr, "fail to process file %s: %w", m.Reference, err)
+			fmt.Fprintf(os.Stderr, "fail to process file %s: %s", m.Reference, err)
 			fmt.Fprintln(os.Stderr)
 		}
 	}
@@ -66,7 +89,7 @@ func Process(m hadock.Message, opt Option) error {
 		out = filepath.Join(opt.Out, m.Reference)
 	)
 
-	ms, err := mmaconv.DefaultTable.Calibrate(in)
+	ms, err := opt.Tables.CalibrateWith(in, mmaconv.Options{Scale: opt.Scale, Filter: opt.filter, Log: opt.logger, Sigma: opt.Sigma})
 	if err != nil {
 		return err
 	}
@@ -90,12 +113,18 @@ func Process(m hadock.Message, opt Option) error {
 	}
 
 	var freq float64
-	if opt.AdjustTime {
-		freq = mmaconv.DefaultTable.SampleFrequency()
+	if opt.AdjustTime && len(ms) > 0 {
+		freq = opt.Tables.Find(ms[0].When).SampleFrequency()
 	}
 
-	ws := csv.NewWriter(w)
-	_, err = dump.Split(ws, ms, freq, opt.DumpFlag())
+	var (
+		ws  = csv.NewWriter(w)
+		set = opt.DumpFlag()
+	)
+	if err := ws.Write(dump.Headers(set)); err != nil {
+		return err
+	}
+	_, err = dump.Split(ws, ms, freq, set)
 
 	ws.Flush()
 	return ws.Error()
@@ -13,12 +13,16 @@ import (
 	"github.com/busoc/mmaconv/cmd/internal/walk"
 )
 
-const Pattern = "%s %4d: %8d (vmu-seq: %6d)"
+const (
+	Pattern    = "%s %4d: %8d (vmu-seq: %6d)"
+	SatPattern = "%s: %8d saturated samples"
+)
 
 func main() {
 	var (
 		verbose   = flag.Bool("v", false, "verbose")
 		summarize = flag.Bool("s", false, "produce a summary")
+		saturated = flag.Bool("w", false, "count saturated samples per file and per day")
 		sched     options.Schedule
 		exlud     options.Exclude
 	)
@@ -47,13 +51,18 @@ func main() {
 			count.Count += s.Count
 			stats.Stats[c] = count
 		}
+		stats.Files = append(stats.Files, stat.Files...)
 	}
+
 	if *summarize {
 		if *verbose && len(stats.Keys) > 0 {
 			fmt.Println("---")
 		}
 		printStat(stats, "summary")
 	}
+	if *saturated {
+		printSaturated(stats)
+	}
 }
 
 func printStat(stat Stat, prefix string) {
@@ -67,6 +76,27 @@ func printStat(stat Stat, prefix string) {
 	}
 }
 
+func printSaturated(stat Stat) {
+	var (
+		days = make(map[string]int)
+		keys []string
+	)
+	for _, f := range stat.Files {
+		fmt.Printf(SatPattern, f.File, f.Count)
+		fmt.Println()
+
+		if _, ok := days[f.Day]; !ok {
+			keys = append(keys, f.Day)
+		}
+		days[f.Day] += f.Count
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf(SatPattern, k, days[k])
+		fmt.Println()
+	}
+}
+
 func splitFile(file string) string {
 	file = filepath.Clean(file)
 	var (
@@ -84,9 +114,16 @@ type Count struct {
 	Count int
 }
 
+type Saturated struct {
+	File  string
+	Day   string
+	Count int
+}
+
 type Stat struct {
 	Keys  []int
 	Stats map[int]Count
+	Files []Saturated
 }
 
 func makeStat() Stat {
@@ -116,6 +153,15 @@ func collect(dir string, sched options.Schedule) Stat {
 		}
 		c.Count++
 		s.Stats[count] = c
+
+		sat := Saturated{
+			File: file,
+			Day:  rs[0].When.Format("2006.002"),
+		}
+		for _, r := range rs {
+			sat.Count += r.Saturated()
+		}
+		s.Files = append(s.Files, sat)
 		return nil
 	})
 	return s
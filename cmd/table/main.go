@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/busoc/mmaconv"
+)
+
+func main() {
+	var (
+		from = flag.Float64("f", -20, "first temperature (degree celsius) of the curves")
+		to   = flag.Float64("t", 60, "last temperature (degree celsius) of the curves")
+		step = flag.Float64("s", 1, "step (degree celsius) between two temperatures of the curves")
+	)
+	flag.Parse()
+
+	var err error
+	switch cmd, files := flag.Arg(0), flag.Args(); cmd {
+	case "check":
+		err = runCheck(files[1:])
+	case "show":
+		err = runShow(files[1:])
+	case "diff":
+		err = runDiff(files[1:])
+	case "curve":
+		err = runCurve(files[1:], *from, *to, *step)
+	default:
+		fmt.Fprintln(os.Stderr, "usage: mmatable [-f from] [-t to] [-s step] <check|show|diff|curve> <table...>")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
+
+func runCheck(files []string) error {
+	var invalid int
+	for _, f := range files {
+		_, es := mmaconv.CheckTable(f)
+		for _, e := range es {
+			fmt.Printf("%s: %s\n", f, e)
+		}
+		if len(es) > 0 {
+			invalid++
+		}
+		tbl, err := load(f)
+		if err != nil {
+			continue
+		}
+		if model(tbl) != model(mmaconv.DefaultTable) {
+			fmt.Printf("%s: model: %q differs from default\n", f, tbl.Model)
+		}
+		for _, d := range mmaconv.DefaultTable.Diff(tbl) {
+			fmt.Printf("%s: %s: %g differs from default (%g)\n", f, d.Field, d.New, d.Old)
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d/%d invalid table(s)", invalid, len(files))
+	}
+	return nil
+}
+
+func runShow(files []string) error {
+	for _, f := range files {
+		tbl, err := load(f)
+		if err != nil {
+			return err
+		}
+		if len(files) > 1 {
+			fmt.Printf("# %s\n", f)
+		}
+		if _, err := tbl.WriteTo(os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runDiff(files []string) error {
+	if len(files) != 2 {
+		return fmt.Errorf("diff: two tables expected")
+	}
+	old, err := load(files[0])
+	if err != nil {
+		return err
+	}
+	curr, err := load(files[1])
+	if err != nil {
+		return err
+	}
+	if model(old) != model(curr) {
+		fmt.Printf("%-16s %24q %24q\n", "model", old.Model, curr.Model)
+	}
+	for _, d := range old.Diff(curr) {
+		fmt.Printf("%-16s %24g %24g\n", d.Field, d.Old, d.New)
+	}
+	return nil
+}
+
+func runCurve(files []string, from, to, step float64) error {
+	if len(files) != 1 {
+		return fmt.Errorf("curve: one table expected")
+	}
+	if step <= 0 {
+		return fmt.Errorf("curve: invalid step %f", step)
+	}
+	tbl, err := load(files[0])
+	if err != nil {
+		return err
+	}
+	var (
+		ws        = csv.NewWriter(os.Stdout)
+		ref, zero = tbl.Reference()
+	)
+	defer ws.Flush()
+
+	ws.Write([]string{
+		"temperature [degC]",
+		"x-scale",
+		"x-offset [microG]",
+		"y-scale",
+		"y-offset [microG]",
+		"z-scale",
+		"z-offset [microG]",
+	})
+	for deg := from; deg <= to; deg += step {
+		var (
+			ax = compensation(tbl.AxisX, deg, ref.X, zero.X)
+			ay = compensation(tbl.AxisY, deg, ref.Y, zero.Y)
+			az = compensation(tbl.AxisZ, deg, ref.Z, zero.Z)
+		)
+		row := []string{
+			formatFloat(deg),
+			formatFloat(tbl.ScaleFactorX(ax)),
+			formatFloat(tbl.TempOffsetX(ax)),
+			formatFloat(tbl.ScaleFactorY(ay)),
+			formatFloat(tbl.TempOffsetY(ay)),
+			formatFloat(tbl.ScaleFactorZ(az)),
+			formatFloat(tbl.TempOffsetZ(az)),
+		}
+		if err := ws.Write(row); err != nil {
+			return err
+		}
+	}
+	ws.Flush()
+	return ws.Error()
+}
+
+// compensation gives the value (mica - reference) used by the polynomials of
+// an axis for a temperature in degree celsius.
+func compensation(abc mmaconv.ABC, deg, ref, zero float64) float64 {
+	mica := ((deg - (ref + zero)) * abc.A1) + abc.A0
+	return mica - ref
+}
+
+// load decodes a table file over DefaultTable like the [c] option of mmaconv
+// but without validating it.
+func load(file string) (mmaconv.Table, error) {
+	tbl := mmaconv.DefaultTable
+	err := tbl.Decode(file)
+	return tbl, err
+}
+
+func model(tbl mmaconv.Table) string {
+	if tbl.Model == "" {
+		return mmaconv.DefaultModel
+	}
+	return tbl.Model
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,156 @@
+package mmaconv
+
+import (
+	"math"
+	"os"
+	"time"
+)
+
+type GapKind uint8
+
+const (
+	// vmu sequences are missing between two files
+	GapMissing GapKind = iota
+	// vmu sequence of a file is the same as the one of the previous file
+	GapRepeat
+	// vmu sequence of a file is lower than the one of the previous file
+	GapBackward
+	// vmu sequence wrapped around the 32-bit range
+	GapWrap
+)
+
+func (k GapKind) String() string {
+	switch k {
+	case GapMissing:
+		return "missing"
+	case GapRepeat:
+		return "repeat"
+	case GapBackward:
+		return "backward"
+	case GapWrap:
+		return "wrap"
+	default:
+		return "unknown"
+	}
+}
+
+// Gap describes a discontinuity of the vmu sequence between two consecutive
+// files. Starts and Ends give the time span not covered by the files: from the
+// end of the previous file to the start of the file. Samples is an estimate of
+// the number of samples lost during this span.
+type Gap struct {
+	Kind     GapKind
+	PrevFile string
+	File     string
+	Prev     uint32
+	Curr     uint32
+	Missing  uint32
+	Starts   time.Time
+	Ends     time.Time
+	Samples  int64
+}
+
+func (g Gap) Duration() time.Duration {
+	return g.Ends.Sub(g.Starts)
+}
+
+// Continuity checks the vmu sequence of consecutive files.
+type Continuity struct {
+	Frequency int64
+
+	started bool
+	file    string
+	header  Header
+	records int
+}
+
+// NewContinuity creates a Continuity for files acquired at the given
+// frequency (Hz).
+func NewContinuity(freq int64) *Continuity {
+	return &Continuity{
+		Frequency: freq,
+	}
+}
+
+// AddFile reads the header of a file and computes its number of records from
+// its size before adding it with Add. A trailing partial record is reported
+// with a DecodeError (ErrRecord) once the file has been added.
+func (c *Continuity) AddFile(file string) (Gap, bool, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return Gap{}, false, err
+	}
+	defer r.Close()
+
+	i, err := r.Stat()
+	if err != nil {
+		return Gap{}, false, err
+	}
+	dec := NewDecoder(r)
+	dec.Name = file
+	hdr, err := dec.Header()
+	if err != nil {
+		return Gap{}, false, err
+	}
+	var (
+		size    = i.Size() - HeaderLen
+		records = size / RecordLen
+	)
+	g, ok := c.Add(file, hdr, int(records))
+	if size%RecordLen != 0 {
+		err = &DecodeError{
+			File:   file,
+			Offset: HeaderLen + (records * RecordLen),
+			Err:    ErrRecord,
+		}
+	}
+	return g, ok, err
+}
+
+// Add gives the discontinuity, if any, between the file and the previous one.
+func (c *Continuity) Add(file string, hdr Header, records int) (Gap, bool) {
+	defer func() {
+		c.started = true
+		c.file = file
+		c.header = hdr
+		c.records = records
+	}()
+	if !c.started {
+		return Gap{}, false
+	}
+	g := Gap{
+		PrevFile: c.file,
+		File:     file,
+		Prev:     c.header.Vid,
+		Curr:     hdr.Vid,
+		Starts:   c.header.When.Add(c.duration(c.records)),
+		Ends:     hdr.When,
+	}
+	diff := hdr.Vid - c.header.Vid
+	switch {
+	case diff == 0:
+		g.Kind = GapRepeat
+	case hdr.Vid < c.header.Vid && diff < math.MaxUint32/2:
+		g.Kind = GapWrap
+		g.Missing = diff - 1
+	case hdr.Vid < c.header.Vid:
+		g.Kind = GapBackward
+	case diff > 1:
+		g.Kind = GapMissing
+		g.Missing = diff - 1
+	default:
+		return g, false
+	}
+	if g.Missing > 0 && g.Ends.After(g.Starts) {
+		g.Samples = int64(math.Round(g.Duration().Seconds() * float64(c.Frequency)))
+	}
+	return g, true
+}
+
+func (c *Continuity) duration(records int) time.Duration {
+	if c.Frequency <= 0 {
+		return 0
+	}
+	secs := float64(records*MeasCount) / float64(c.Frequency)
+	return time.Duration(secs * float64(time.Second))
+}
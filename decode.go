@@ -0,0 +1,142 @@
+package mmaconv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	HeaderLen = 16
+	RecordLen = 64
+	RawCount  = (RecordLen - 2) / 2
+)
+
+var (
+	ErrMagic  = errors.New("invalid FCC")
+	ErrHeader = errors.New("truncated header")
+	ErrRecord = errors.New("truncated record")
+)
+
+type DecodeError struct {
+	File   string
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("offset %d: %s", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("%s: offset %d: %s", e.File, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+type Header struct {
+	Vid  uint32
+	When time.Time
+}
+
+type Decoder struct {
+	Name  string
+	Scale TimeScale
+
+	reader *bufio.Reader
+	offset int64
+	header Header
+	err    error
+	done   bool
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		reader: bufio.NewReader(r),
+	}
+}
+
+func (d *Decoder) Offset() int64 {
+	return d.offset
+}
+
+func (d *Decoder) Header() (Header, error) {
+	if !d.done {
+		d.header, d.err = d.readHeader()
+		d.done = true
+	}
+	return d.header, d.err
+}
+
+func (d *Decoder) Decode() (Record, error) {
+	var rec Record
+	if _, err := d.Header(); err != nil {
+		return rec, err
+	}
+	buf := make([]byte, RecordLen)
+	if err := d.read(buf, ErrRecord); err != nil {
+		return rec, err
+	}
+	return unmarshalRecord(buf, d.header), nil
+}
+
+func (d *Decoder) readHeader() (Header, error) {
+	var (
+		hdr Header
+		buf = make([]byte, HeaderLen)
+	)
+	if err := d.read(buf, ErrHeader); err != nil {
+		if err == io.EOF {
+			err = d.error(0, ErrHeader)
+		}
+		return hdr, err
+	}
+	if !bytes.Equal(buf[:4], Magic) {
+		return hdr, d.error(0, fmt.Errorf("%w %q", ErrMagic, buf[:4]))
+	}
+	return unmarshalHeader(buf, d.Scale), nil
+}
+
+func (d *Decoder) read(buf []byte, short error) error {
+	n, err := io.ReadFull(d.reader, buf)
+	if err == io.ErrUnexpectedEOF {
+		err = d.error(d.offset, short)
+	}
+	d.offset += int64(n)
+	return err
+}
+
+func (d *Decoder) error(offset int64, err error) error {
+	return &DecodeError{
+		File:   d.Name,
+		Offset: offset,
+		Err:    err,
+	}
+}
+
+func unmarshalHeader(buf []byte, scale TimeScale) Header {
+	when := Epoch.Add(time.Duration(binary.BigEndian.Uint64(buf[8:])))
+	return Header{
+		Vid:  binary.BigEndian.Uint32(buf[4:]),
+		When: scale.FromGPS(when),
+	}
+}
+
+func unmarshalRecord(buf []byte, hdr Header) Record {
+	rec := Record{
+		Seq:  binary.BigEndian.Uint16(buf),
+		Raw:  make([]int16, RawCount),
+		When: hdr.When,
+		Vid:  hdr.Vid,
+	}
+	for i := range rec.Raw {
+		rec.Raw[i] = int16(binary.BigEndian.Uint16(buf[2+(i*2):]))
+	}
+	rec.Status = Status(rec.Raw[StatusIndex])
+	return rec
+}
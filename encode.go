@@ -0,0 +1,92 @@
+package mmaconv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+type Encoder struct {
+	Scale TimeScale
+
+	writer io.Writer
+	done   bool
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		writer: w,
+	}
+}
+
+func (e *Encoder) WriteHeader(hdr Header) error {
+	if e.done {
+		return fmt.Errorf("header already written")
+	}
+	buf := make([]byte, HeaderLen)
+	copy(buf, Magic)
+	binary.BigEndian.PutUint32(buf[4:], hdr.Vid)
+	binary.BigEndian.PutUint64(buf[8:], uint64(e.Scale.ToGPS(hdr.When).Sub(Epoch)))
+
+	_, err := e.writer.Write(buf)
+	e.done = err == nil
+	return err
+}
+
+func (e *Encoder) Encode(rec Record) error {
+	if !e.done {
+		hdr := Header{
+			Vid:  rec.Vid,
+			When: rec.When,
+		}
+		if err := e.WriteHeader(hdr); err != nil {
+			return err
+		}
+	}
+	if len(rec.Raw) != RawCount {
+		return fmt.Errorf("record %d: invalid number of values (%d)", rec.Seq, len(rec.Raw))
+	}
+	_, err := e.writer.Write(marshalRecord(rec))
+	return err
+}
+
+func (e *Encoder) EncodeMMA(m MMA) error {
+	hdr := Header{
+		Vid:  m.Vid,
+		When: m.When,
+	}
+	if err := e.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := e.writer.Write(m.Raw)
+	return err
+}
+
+func WriteFile(file string, hdr Header, rs []Record) error {
+	w, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	enc := NewEncoder(w)
+	if err = enc.WriteHeader(hdr); err == nil {
+		for _, r := range rs {
+			if err = enc.Encode(r); err != nil {
+				break
+			}
+		}
+	}
+	if e := w.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func marshalRecord(r Record) []byte {
+	buf := make([]byte, RecordLen)
+	binary.BigEndian.PutUint16(buf, r.Seq)
+	for i := 0; i < len(r.Raw) && i < RawCount; i++ {
+		binary.BigEndian.PutUint16(buf[2+(i*2):], uint16(r.Raw[i]))
+	}
+	return buf
+}
@@ -0,0 +1,133 @@
+package mmaconv
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BadExt     = ".bad"
+	TimeLayout = "20060102_150405"
+)
+
+const (
+	// DefaultPattern is the naming scheme used by hadock for the files of both
+	// its realtime and playback archives.
+	DefaultPattern = "{origin}_{upi}_{instance}_{sequence}_{time}_{offset}"
+	// ShortPattern is the naming scheme of files without the offset field.
+	ShortPattern = "{origin}_{upi}_{instance}_{sequence}_{time}"
+)
+
+// Patterns are the naming schemes tried by ParseFilename when no pattern is
+// given. Fields of a pattern are separated by an underscore and can be one of
+// {origin}, {upi}, {instance}, {sequence}, {time} and {offset}. Only {upi} can
+// itself contain underscores.
+var Patterns = []string{
+	DefaultPattern,
+	ShortPattern,
+}
+
+type Filename struct {
+	Origin   string
+	UPI      string
+	Instance int
+	Sequence int
+	When     time.Time
+	Offset   time.Duration
+	Ext      string
+	Bad      bool
+}
+
+func ParseFilename(file string, patterns ...string) (Filename, error) {
+	if len(patterns) == 0 {
+		patterns = Patterns
+	}
+	var (
+		fn   Filename
+		base = filepath.Base(file)
+		err  = fmt.Errorf("%s: no pattern given", base)
+	)
+	for _, p := range patterns {
+		if fn, err = parseFilename(base, p); err == nil {
+			return fn, nil
+		}
+	}
+	return Filename{}, err
+}
+
+func parseFilename(base, pattern string) (Filename, error) {
+	var fn Filename
+	if strings.HasSuffix(base, BadExt) {
+		fn.Bad = true
+		base = strings.TrimSuffix(base, BadExt)
+	}
+	fn.Ext = filepath.Ext(base)
+	base = strings.TrimSuffix(base, fn.Ext)
+
+	var (
+		parts  = strings.Split(base, "_")
+		fields = strings.Split(pattern, "_")
+		timlen = strings.Count(TimeLayout, "_") + 1
+	)
+	// fields are consumed from the start until {upi} and from the end after it
+	for i, j := 0, 0; i < len(fields); i++ {
+		f := fields[i]
+		if f == "{upi}" {
+			rest := fields[i+1:]
+			n := 0
+			for _, r := range rest {
+				n++
+				if r == "{time}" {
+					n += timlen - 1
+				}
+			}
+			if len(parts)-j-n < 1 {
+				return fn, fmt.Errorf("%s: too few fields for pattern %s", base, pattern)
+			}
+			fn.UPI = strings.Join(parts[j:len(parts)-n], "_")
+			j = len(parts) - n
+			continue
+		}
+		n := 1
+		if f == "{time}" {
+			n = timlen
+		}
+		if j+n > len(parts) {
+			return fn, fmt.Errorf("%s: too few fields for pattern %s", base, pattern)
+		}
+		if err := fn.set(f, strings.Join(parts[j:j+n], "_")); err != nil {
+			return fn, fmt.Errorf("%s: %s: %w", base, f, err)
+		}
+		j += n
+		if i == len(fields)-1 && j != len(parts) {
+			return fn, fmt.Errorf("%s: too many fields for pattern %s", base, pattern)
+		}
+	}
+	return fn, nil
+}
+
+func (f *Filename) set(field, value string) error {
+	var err error
+	switch field {
+	case "{origin}":
+		f.Origin = value
+	case "{instance}":
+		f.Instance, err = strconv.Atoi(value)
+	case "{sequence}":
+		f.Sequence, err = strconv.Atoi(value)
+	case "{time}":
+		f.When, err = time.Parse(TimeLayout, value)
+	case "{offset}":
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 64)
+		f.Offset = time.Duration(n) * time.Minute
+	default:
+		if field != value {
+			err = fmt.Errorf("unexpected value %s", value)
+		}
+	}
+	return err
+}
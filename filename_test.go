@@ -0,0 +1,92 @@
+package mmaconv
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFilename(t *testing.T) {
+	when := time.Date(2021, 5, 29, 10, 10, 10, 0, time.UTC)
+	data := []struct {
+		File     string
+		Patterns []string
+		Want     Filename
+		Fail     bool
+	}{
+		{
+			File: "0051_SCIENCE_3_000100_20210529_101010_000000005.dat",
+			Want: Filename{Origin: "0051", UPI: "SCIENCE", Instance: 3, Sequence: 100, When: when, Offset: 5 * time.Minute, Ext: ".dat"},
+		},
+		{
+			File: "tmp/mma/0051_SCIENCE_3_000000_20210529_101010.dat",
+			Want: Filename{Origin: "0051", UPI: "SCIENCE", Instance: 3, Sequence: 0, When: when, Ext: ".dat"},
+		},
+		{
+			File: "0051_MMA_SCIENCE_DATA_3_000100_20210529_101010_000000000.dat",
+			Want: Filename{Origin: "0051", UPI: "MMA_SCIENCE_DATA", Instance: 3, Sequence: 100, When: when, Ext: ".dat"},
+		},
+		{
+			File: "0051_MMA_SCIENCE_3_000100_20210529_101010.dat",
+			Want: Filename{Origin: "0051", UPI: "MMA_SCIENCE", Instance: 3, Sequence: 100, When: when, Ext: ".dat"},
+		},
+		{
+			File: "0051_SCIENCE_3_000100_20210529_101010_000000000.dat.bad",
+			Want: Filename{Origin: "0051", UPI: "SCIENCE", Instance: 3, Sequence: 100, When: when, Ext: ".dat", Bad: true},
+		},
+		{
+			File: "0051_SCIENCE_3_000100_20210529_101010.bad",
+			Want: Filename{Origin: "0051", UPI: "SCIENCE", Instance: 3, Sequence: 100, When: when, Bad: true},
+		},
+		{
+			File:     "0051_3_000100.dat",
+			Patterns: []string{"{origin}_{instance}_{sequence}"},
+			Want:     Filename{Origin: "0051", Instance: 3, Sequence: 100, Ext: ".dat"},
+		},
+		{
+			File: "0051_SCIENCE_3.dat",
+			Fail: true,
+		},
+		{
+			File: "0051_SCIENCE_3_000100_20210529.dat",
+			Fail: true,
+		},
+		{
+			File: "0051_3_000100_20210529_101010.dat",
+			Fail: true,
+		},
+		{
+			File: "0051_SCIENCE_3_000100_20210529_101010_000000000_7.dat",
+			Fail: true,
+		},
+		{
+			File:     "0051_3_000100_7.dat",
+			Patterns: []string{"{origin}_{instance}_{sequence}"},
+			Fail:     true,
+		},
+		{
+			File:     "0051_3.dat",
+			Patterns: []string{"{origin}_{instance}_{sequence}"},
+			Fail:     true,
+		},
+		{
+			File: "0051_SCIENCE_x_000100_20210529_101010_000000000.dat",
+			Fail: true,
+		},
+	}
+	for _, d := range data {
+		got, err := ParseFilename(d.File, d.Patterns...)
+		if d.Fail {
+			if err == nil {
+				t.Errorf("%s: expected error, got %+v", d.File, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", d.File, err)
+			continue
+		}
+		if got != d.Want {
+			t.Errorf("%s: want %+v, got %+v", d.File, d.Want, got)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package mmaconv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxFilterGap is the maximum time between two consecutive records before the
+// state of a Filter is reset.
+const MaxFilterGap = time.Second
+
+type FilterKind uint8
+
+const (
+	NoFilter FilterKind = iota
+	MovingAverage
+	LowPass
+)
+
+// Filter smooths the raw temperatures of consecutive records (across files)
+// before they are used to compensate the accelerations. It is given as
+// "none", "avg:<size>" (moving average over the last size records) or
+// "lowpass:<alpha>" (first order low pass filter with 0 < alpha <= 1).
+type Filter struct {
+	Kind  FilterKind
+	Size  int
+	Alpha float64
+
+	window [][3]float64
+	sum    [3]float64
+	last   [3]float64
+	count  int64
+	init   bool
+}
+
+func NewFilter(kind FilterKind, size int, alpha float64) *Filter {
+	return &Filter{
+		Kind:  kind,
+		Size:  size,
+		Alpha: alpha,
+	}
+}
+
+func (f *Filter) Set(str string) error {
+	kind, param := str, ""
+	if x := strings.Index(str, ":"); x >= 0 {
+		kind, param = str[:x], str[x+1:]
+	}
+	switch strings.ToLower(kind) {
+	case "none", "":
+		*f = Filter{}
+	case "avg", "average":
+		size, err := strconv.Atoi(param)
+		if err != nil || size <= 0 {
+			return fmt.Errorf("%s: invalid size of moving average", param)
+		}
+		*f = Filter{Kind: MovingAverage, Size: size}
+	case "lowpass":
+		alpha, err := strconv.ParseFloat(param, 64)
+		if err != nil || alpha <= 0 || alpha > 1 {
+			return fmt.Errorf("%s: invalid factor of low pass filter", param)
+		}
+		*f = Filter{Kind: LowPass, Alpha: alpha}
+	default:
+		return fmt.Errorf("%s: unknown filter", kind)
+	}
+	return nil
+}
+
+func (f *Filter) String() string {
+	switch f.Kind {
+	case MovingAverage:
+		return fmt.Sprintf("avg:%d", f.Size)
+	case LowPass:
+		return fmt.Sprintf("lowpass:%s", strconv.FormatFloat(f.Alpha, 'f', -1, 64))
+	default:
+		return "none"
+	}
+}
+
+func (f *Filter) Reset() {
+	f.window = f.window[:0]
+	f.sum = [3]float64{}
+	f.last = [3]float64{}
+	f.init = false
+}
+
+// Apply sets the filtered temperatures of the records. step is the nominal
+// step of the sequence counter between two records (see Timeline.Step). The
+// state of the filter is reset when the sequence counter is reset or when the
+// gap between two records is greater than MaxFilterGap or, for records more
+// than MaxFilterGap apart, greater than two steps. Records flagged with
+// QualityTemperature are not filtered and do not change the state of the
+// filter.
+func (f *Filter) Apply(rs []Record, step int64) {
+	if f == nil || f.Kind == NoFilter {
+		return
+	}
+	gap := int64(MaxFilterGap.Seconds() * TickRate)
+	if g := 2 * step; g > gap {
+		gap = g
+	}
+	for i := range rs {
+		if rs[i].Flags.Has(QualityTemperature) {
+			continue
+		}
+		c := rs[i].Count
+		if f.init && (rs[i].Reason == ReasonReset || c < f.count || c-f.count > gap) {
+			f.Reset()
+		}
+		rs[i].Temp = f.next(rs[i])
+		f.count = rs[i].Count
+	}
+}
+
+func (f *Filter) next(rec Record) []float64 {
+	var curr [3]float64
+	for j := range curr {
+		curr[j] = float64(rec.Raw[j])
+	}
+	switch f.Kind {
+	case MovingAverage:
+		if len(f.window) >= f.Size {
+			for j := range curr {
+				f.sum[j] -= f.window[0][j]
+			}
+			f.window = f.window[1:]
+		}
+		f.window = append(f.window, curr)
+		for j := range curr {
+			f.sum[j] += curr[j]
+			f.last[j] = f.sum[j] / float64(len(f.window))
+		}
+	case LowPass:
+		for j := range curr {
+			if !f.init {
+				f.last[j] = curr[j]
+			} else {
+				f.last[j] += f.Alpha * (curr[j] - f.last[j])
+			}
+		}
+	}
+	f.init = true
+	return []float64{f.last[0], f.last[1], f.last[2]}
+}
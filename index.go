@@ -0,0 +1,127 @@
+package mmaconv
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+)
+
+type indexKey struct {
+	Vid uint32
+	Seq uint16
+	Sum uint32
+}
+
+// Index keeps track of the records already seen across multiple files.
+type Index struct {
+	seen map[indexKey]struct{}
+}
+
+func NewIndex() *Index {
+	return &Index{
+		seen: make(map[indexKey]struct{}),
+	}
+}
+
+// LoadIndex reads an index previously saved with Save. An empty index is
+// returned if the file does not exist.
+func LoadIndex(file string) (*Index, error) {
+	x := NewIndex()
+	if file == "" {
+		return x, nil
+	}
+	r, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return x, err
+	}
+	defer r.Close()
+
+	rs := csv.NewReader(r)
+	rs.FieldsPerRecord = 3
+	for {
+		row, err := rs.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		vid, err := strconv.ParseUint(row[0], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		seq, err := strconv.ParseUint(row[1], 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := strconv.ParseUint(row[2], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		k := indexKey{
+			Vid: uint32(vid),
+			Seq: uint16(seq),
+			Sum: uint32(sum),
+		}
+		x.seen[k] = struct{}{}
+	}
+	return x, nil
+}
+
+func (x *Index) Save(file string) error {
+	w, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	var (
+		ws  = csv.NewWriter(w)
+		str = make([]string, 3)
+	)
+	for k := range x.seen {
+		str[0] = strconv.FormatUint(uint64(k.Vid), 10)
+		str[1] = strconv.FormatUint(uint64(k.Seq), 10)
+		str[2] = strconv.FormatUint(uint64(k.Sum), 10)
+		if err = ws.Write(str); err != nil {
+			break
+		}
+	}
+	ws.Flush()
+	if err == nil {
+		err = ws.Error()
+	}
+	if e := w.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func (x *Index) Len() int {
+	return len(x.seen)
+}
+
+func (x *Index) Has(rec Record) bool {
+	_, ok := x.seen[makeKey(rec)]
+	return ok
+}
+
+func (x *Index) Add(rec Record) {
+	x.seen[makeKey(rec)] = struct{}{}
+}
+
+func (x *Index) AddMeasurements(ms []Measurement) {
+	for _, m := range ms {
+		x.Add(m.Record)
+	}
+}
+
+func makeKey(rec Record) indexKey {
+	return indexKey{
+		Vid: rec.Vid,
+		Seq: rec.Seq,
+		Sum: rec.Checksum(),
+	}
+}
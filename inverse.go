@@ -0,0 +1,132 @@
+package mmaconv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrClipped = errors.New("values clipped")
+
+// Uncalibrator converts calibrated temperatures and accelerations back into
+// the raw values of a record.
+type Uncalibrator interface {
+	Uncalibrate(m Measurement) (Record, error)
+}
+
+// Uncalibrate converts the temperatures (degree celsius) and accelerations
+// (micro g) of a measurement back into raw counts. The record is returned even
+// if some values have been clipped, the error then wraps ErrClipped.
+func (t *Table) Uncalibrate(m Measurement) (Record, error) {
+	c, err := t.Calibrator()
+	if err != nil {
+		return m.Record, err
+	}
+	u, ok := c.(Uncalibrator)
+	if !ok {
+		return m.Record, fmt.Errorf("%s: model can not be inverted", t.Model)
+	}
+	return u.Uncalibrate(m)
+}
+
+// CheckInverse verifies that the table can be inverted for temperatures going
+// from min to max (degree celsius) by the given step.
+func (t *Table) CheckInverse(min, max, step float64) error {
+	if step <= 0 {
+		return fmt.Errorf("invalid step %f", step)
+	}
+	for deg := min; deg <= max; deg += step {
+		for _, a := range t.axes() {
+			raw, clip := a.RawTemperature(deg, a.Ref+a.Zero)
+			if clip || math.IsNaN(raw) {
+				return fmt.Errorf("%s-axis: %.2fdegC: temperature out of range", a.Name, deg)
+			}
+			mica, _ := a.TemperaturesAt(raw, a.Ref+a.Zero)
+			sf := a.ScaleFactor(a.Scale, mica-a.Ref)
+			if sf == 0 || math.IsNaN(sf) || math.IsInf(sf, 0) {
+				return fmt.Errorf("%s-axis: %.2fdegC: scale factor can not be inverted", a.Name, deg)
+			}
+		}
+	}
+	return nil
+}
+
+type axis struct {
+	ABC
+	Name   string
+	Ref    float64
+	Zero   float64
+	Scale  float64
+	Offset float64
+}
+
+func (t *Table) axes() []axis {
+	ref, zero := t.Reference()
+	return []axis{
+		{ABC: t.AxisX, Name: "x", Ref: ref.X, Zero: zero.X, Scale: t.Scale.X, Offset: t.Offset.X},
+		{ABC: t.AxisY, Name: "y", Ref: ref.Y, Zero: zero.Y, Scale: t.Scale.Y, Offset: t.Offset.Y},
+		{ABC: t.AxisZ, Name: "z", Ref: ref.Z, Zero: zero.Z, Scale: t.Scale.Z, Offset: t.Offset.Z},
+	}
+}
+
+// RawTemperature gives the raw temperature word of a temperature in degree
+// celsius and whether it has been clipped.
+func (a ABC) RawTemperature(celsius, ref float64) (float64, bool) {
+	mica := ((celsius - ref) * a.A1) + a.A0
+	return clip((mica - 272.48) / 2.803e-03)
+}
+
+func (p Polynomial) Uncalibrate(m Measurement) (Record, error) {
+	var (
+		rec     = m.Record
+		raw     = make([]int16, RawCount)
+		degs    = []float64{m.DegX, m.DegY, m.DegZ}
+		accs    = [][]float64{m.AccX, m.AccY, m.AccZ}
+		clipped int
+	)
+	for i, a := range p.table.axes() {
+		temp, clip := a.RawTemperature(degs[i], a.Ref+a.Zero)
+		if clip {
+			clipped++
+		}
+		raw[i] = int16(temp)
+
+		// use the quantized temperature as Calibrate does
+		mica, _ := a.TemperaturesAt(temp, a.Ref+a.Zero)
+		var (
+			ai  = mica - a.Ref
+			sf  = a.ScaleFactor(a.Scale, ai)
+			off = a.TempOffset(a.Offset, ai)
+		)
+		for j := 0; j < len(accs[i]) && j < MeasCount; j++ {
+			v, clip := unapply(accs[i][j], sf, off)
+			if clip {
+				clipped++
+			}
+			raw[4+i+(j*3)] = int16(v)
+		}
+	}
+	raw[StatusIndex] = int16(rec.Status)
+
+	rec.Raw = raw
+	if clipped > 0 {
+		return rec, fmt.Errorf("%w: %d", ErrClipped, clipped)
+	}
+	return rec, nil
+}
+
+func unapply(v, sf, off float64) (float64, bool) {
+	return clip((v + off) / sf)
+}
+
+func clip(v float64) (float64, bool) {
+	v = math.Round(v)
+	switch {
+	case v >= MaxValue:
+		return MaxValue - 1, true
+	case v < -MaxValue:
+		return -MaxValue, true
+	default:
+		return v, false
+	}
+}
@@ -0,0 +1,127 @@
+package mmaconv
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrRange = errors.New("range should be given as [min, max]")
+
+// Limit gives the range of valid temperatures (degree celsius) and of
+// plausible accelerations (micro g) of an axis as [min, max]. No check is
+// performed when a range is not given. Temperatures are checked before being
+// filtered.
+type Limit struct {
+	Temperature  []float64
+	Acceleration []float64
+}
+
+// Limits gives the ranges of valid values of each axis. Records having a value
+// outside these ranges are flagged unless Reject is set, they are then removed
+// from the output of Calibrate.
+type Limits struct {
+	Reject bool
+
+	AxisX Limit `toml:"x-axis"`
+	AxisY Limit `toml:"y-axis"`
+	AxisZ Limit `toml:"z-axis"`
+}
+
+// Violation describes a value of a measurement outside the limits of a table.
+type Violation struct {
+	Field string
+	Value float64
+	Range []float64
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %g outside [%g, %g]", v.Field, v.Value, v.Range[0], v.Range[1])
+}
+
+func (i Limits) validate() error {
+	for n, x := range []Limit{i.AxisX, i.AxisY, i.AxisZ} {
+		if err := checkRange(x.Temperature); err != nil {
+			return fmt.Errorf("%c-axis.temperature: %w", 'x'+n, err)
+		}
+		if err := checkRange(x.Acceleration); err != nil {
+			return fmt.Errorf("%c-axis.acceleration: %w", 'x'+n, err)
+		}
+	}
+	return nil
+}
+
+// Check gives the quality flags of the measurement for the limits and the
+// values outside them.
+func (i Limits) Check(m Measurement) (Quality, []Violation) {
+	var (
+		q    Quality
+		list []Violation
+		axes = []struct {
+			Name  string
+			Limit Limit
+			Deg   float64
+			Acc   []float64
+		}{
+			{Name: "x-axis", Limit: i.AxisX, Deg: m.RawDegX, Acc: m.AccX},
+			{Name: "y-axis", Limit: i.AxisY, Deg: m.RawDegY, Acc: m.AccY},
+			{Name: "z-axis", Limit: i.AxisZ, Deg: m.RawDegZ, Acc: m.AccZ},
+		}
+	)
+	for _, a := range axes {
+		if r := a.Limit.Temperature; !inRange(r, a.Deg) {
+			q |= QualityTemperature
+			list = append(list, Violation{Field: a.Name + " temperature", Value: a.Deg, Range: r})
+		}
+		for _, v := range a.Acc {
+			if r := a.Limit.Acceleration; !inRange(r, v) {
+				q |= QualityAcceleration
+				list = append(list, Violation{Field: a.Name + " acceleration", Value: v, Range: r})
+				break
+			}
+		}
+	}
+	return q, list
+}
+
+// checkRaw gives QualityTemperature if the temperature of an axis computed
+// from the raw temperature of the record is outside the limits.
+func (i Limits) checkRaw(t Table, rec Record) Quality {
+	var (
+		ref, zero = t.Reference()
+		axes      = []struct {
+			ABC   ABC
+			Range []float64
+			Ref   float64
+		}{
+			{ABC: t.AxisX, Range: i.AxisX.Temperature, Ref: ref.X + zero.X},
+			{ABC: t.AxisY, Range: i.AxisY.Temperature, Ref: ref.Y + zero.Y},
+			{ABC: t.AxisZ, Range: i.AxisZ.Temperature, Ref: ref.Z + zero.Z},
+		}
+	)
+	for j, a := range axes {
+		if len(a.Range) == 0 {
+			continue
+		}
+		if _, deg := a.ABC.TemperaturesAt(float64(rec.Raw[j]), a.Ref); !inRange(a.Range, deg) {
+			return QualityTemperature
+		}
+	}
+	return 0
+}
+
+func checkRange(r []float64) error {
+	if len(r) == 0 {
+		return nil
+	}
+	if len(r) != 2 || r[0] > r[1] {
+		return ErrRange
+	}
+	return nil
+}
+
+func inRange(r []float64, v float64) bool {
+	if len(r) != 2 {
+		return true
+	}
+	return v >= r[0] && v <= r[1]
+}
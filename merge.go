@@ -0,0 +1,134 @@
+package mmaconv
+
+import (
+	"os"
+	"sort"
+)
+
+// Source tells from which copies of a file, realtime and/or playback, a record
+// has been taken.
+type Source uint8
+
+const (
+	Realtime Source = 1 << iota
+	Playback
+
+	Both = Realtime | Playback
+)
+
+func (s Source) String() string {
+	switch s {
+	case Realtime:
+		return "realtime"
+	case Playback:
+		return "playback"
+	case Both:
+		return "both"
+	default:
+		return ""
+	}
+}
+
+type mergeKey struct {
+	Vid uint32
+	Seq uint16
+}
+
+// Merge merges the records of the realtime and playback copies of the same
+// file. Records are matched by their vmu and milbus sequence counters. When a
+// record is found in both copies, the one of the copy having the most records
+// is kept. The Source of each record tells in which copies it was found. freq
+// is the sampling frequency (Hz) of the sensor.
+func Merge(realtime, playback []Record, freq int64) []Record {
+	var (
+		primary   = NewTimeline(freq).Order(playback)
+		secondary = NewTimeline(freq).Order(realtime)
+		psrc      = Playback
+		ssrc      = Realtime
+	)
+	if len(secondary) > len(primary) {
+		primary, secondary = secondary, primary
+		psrc, ssrc = ssrc, psrc
+	}
+	var (
+		index   = make(map[mergeKey]int)
+		data    = make([]Record, 0, len(primary)+len(secondary))
+		extras  []Record
+		offset  int64
+		aligned bool
+	)
+	for _, r := range primary {
+		r.Source = psrc
+		index[mergeKey{Vid: r.Vid, Seq: r.Seq}] = len(data)
+		data = append(data, r)
+	}
+	for _, r := range secondary {
+		if i, ok := index[mergeKey{Vid: r.Vid, Seq: r.Seq}]; ok {
+			data[i].Source |= ssrc
+			if !aligned {
+				offset, aligned = data[i].Count-r.Count, true
+			}
+			continue
+		}
+		r.Source = ssrc
+		extras = append(extras, r)
+	}
+	for _, r := range extras {
+		r.Count += offset
+		data = append(data, r)
+	}
+	sort.SliceStable(data, func(i, j int) bool {
+		return data[i].Count < data[j].Count
+	})
+	for i := range data {
+		// gaps of one copy can be filled by the other one
+		data[i].Flags &^= QualityGap
+	}
+	return data
+}
+
+// MergeWith decodes the realtime and playback copies of a file, one of them
+// can be empty if the file is only found in one stream, and gives their merged
+// records ordered with the options.
+func MergeWith(realtime, playback string, opt Options) ([]Record, error) {
+	rt, err1 := mergeFile(realtime, opt)
+	if err1 != nil && !opt.Partial {
+		return nil, err1
+	}
+	pb, err2 := mergeFile(playback, opt)
+	if err2 != nil && !opt.Partial {
+		return nil, err2
+	}
+	err := err1
+	if err == nil {
+		err = err2
+	}
+	data := Merge(rt, pb, opt.timeline().Frequency())
+	if len(data) == 0 {
+		return nil, err
+	}
+	return opt.finish(data), err
+}
+
+func mergeFile(file string, opt Options) ([]Record, error) {
+	if file == "" {
+		return nil, nil
+	}
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	dec := NewDecoder(r)
+	dec.Name = file
+	dec.Scale = opt.Scale
+	return decode(dec, opt)
+}
+
+func mergeName(realtime, playback string) string {
+	if playback != "" {
+		return playback
+	}
+	return realtime
+}
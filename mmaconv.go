@@ -2,14 +2,14 @@ package mmaconv
 
 import (
 	"bytes"
-	"encoding/binary"
 	"fmt"
 	"hash/adler32"
 	"io"
 	"io/ioutil"
+	"log"
 	"math"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -49,6 +49,11 @@ var DefaultTable = Table{
 		Y: 293,
 		Z: 293,
 	},
+	Zero: XYZ{
+		X: TempZero,
+		Y: TempZero,
+		Z: TempZero,
+	},
 	Scale: XYZ{
 		X: 3.452,
 		Y: 3.432,
@@ -122,12 +127,40 @@ type Measurement struct {
 	AccY []float64
 	AccZ []float64
 
+	// raw counts of the accelerations at the limits of their range
+	SatX []bool
+	SatY []bool
+	SatZ []bool
+
+	// accelerations in the station body frame
+	BodyX []float64
+	BodyY []float64
+	BodyZ []float64
+
 	ScaleX  float64
 	OffsetX float64
 	ScaleY  float64
 	OffsetY float64
 	ScaleZ  float64
 	OffsetZ float64
+
+	// reference temperatures (kelvin) used for the compensation
+	RefX float64
+	RefY float64
+	RefZ float64
+
+	// temperatures computed from the unfiltered raw temperatures
+	RawDegX float64
+	RawDegY float64
+	RawDegZ float64
+
+	// standard uncertainties of the temperatures and accelerations
+	SigmaDegX float64
+	SigmaDegY float64
+	SigmaDegZ float64
+	SigmaAccX []float64
+	SigmaAccY []float64
+	SigmaAccZ []float64
 }
 
 type ABC struct {
@@ -146,8 +179,14 @@ type ABC struct {
 }
 
 func (a ABC) Temperatures(raw float64) (mica, celsius float64) {
+	return a.TemperaturesAt(raw, TempMMA)
+}
+
+// TemperaturesAt is like Temperatures but with the reference temperature (in
+// degree celsius) of the calibration given.
+func (a ABC) TemperaturesAt(raw, ref float64) (mica, celsius float64) {
 	mica = (raw * 2.803e-03) + 272.48
-	celsius = ((mica - a.A0) / a.A1) + TempMMA
+	celsius = ((mica - a.A0) / a.A1) + ref
 	return
 }
 
@@ -176,24 +215,190 @@ type XYZ struct {
 
 type Table struct {
 	Frequency int64
+	Model     string
 
+	// reference temperatures (kelvin) and zero points (degree celsius)
 	Calib  XYZ `toml:"calibration"`
+	Zero   XYZ
 	Scale  XYZ
 	Offset XYZ
 
 	AxisX ABC `toml:"x-axis"`
 	AxisY ABC `toml:"y-axis"`
 	AxisZ ABC `toml:"z-axis"`
+
+	Sigma  Uncertainty `toml:"uncertainty"`
+	Align  Alignment   `toml:"alignment"`
+	Limits Limits      `toml:"limits"`
 }
 
 func (t *Table) Set(file string) error {
-	return toml.DecodeFile(file, t)
+	if err := t.Decode(file); err != nil {
+		return err
+	}
+	if es := t.Validate(); len(es) > 0 {
+		return fmt.Errorf("%s: %w", file, es[0])
+	}
+	return nil
 }
 
 func (t *Table) String() string {
 	return "parameters table file"
 }
 
+// Decode decodes a table file over the table without validating it. The
+// reference temperatures and the zero points not given in the file are set to
+// their defaults (see Reference).
+func (t *Table) Decode(file string) error {
+	keys := make(map[string]interface{})
+	if err := toml.DecodeFile(file, t); err != nil {
+		return err
+	}
+	if err := toml.DecodeFile(file, &keys); err != nil {
+		return err
+	}
+	t.setReference(keys)
+	return nil
+}
+
+// setReference sets the reference temperatures and the zero points missing from
+// the keys of a table file to their defaults.
+func (t *Table) setReference(keys map[string]interface{}) {
+	var (
+		calib, _ = keys["calibration"].(map[string]interface{})
+		zero, _  = keys["zero"].(map[string]interface{})
+	)
+	axes := []struct {
+		Key  string
+		Ref  *float64
+		Zero *float64
+	}{
+		{Key: "X", Ref: &t.Calib.X, Zero: &t.Zero.X},
+		{Key: "Y", Ref: &t.Calib.Y, Zero: &t.Zero.Y},
+		{Key: "Z", Ref: &t.Calib.Z, Zero: &t.Zero.Z},
+	}
+	for _, a := range axes {
+		if _, ok := zero[a.Key]; !ok {
+			*a.Zero = TempZero
+		}
+		if _, ok := calib[a.Key]; !ok {
+			*a.Ref = TempMMA - *a.Zero
+		}
+	}
+}
+
+// WriteTo writes the table in the toml format accepted by Set.
+func (t Table) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "frequency = %d\n", t.Frequency)
+	if t.Model != "" {
+		fmt.Fprintf(&buf, "model = %q\n", t.Model)
+	}
+	writeXYZ(&buf, "calibration", t.Calib)
+	writeXYZ(&buf, "zero", t.Zero)
+	writeXYZ(&buf, "scale", t.Scale)
+	writeXYZ(&buf, "offset", t.Offset)
+	writeABC(&buf, "x-axis", t.AxisX)
+	writeABC(&buf, "y-axis", t.AxisY)
+	writeABC(&buf, "z-axis", t.AxisZ)
+	if t.Sigma != (Uncertainty{}) {
+		u := t.Sigma
+		writeXYZ(&buf, "uncertainty.scale", u.Scale)
+		writeXYZ(&buf, "uncertainty.offset", u.Offset)
+		writeXYZ(&buf, "uncertainty.temperature", u.Temperature)
+		writeXYZ(&buf, "uncertainty.acceleration", u.Acceleration)
+		writeABC(&buf, "uncertainty.x-axis", u.AxisX)
+		writeABC(&buf, "uncertainty.y-axis", u.AxisY)
+		writeABC(&buf, "uncertainty.z-axis", u.AxisZ)
+	}
+	writeAlignment(&buf, t.Align)
+	if t.Limits.Reject {
+		fmt.Fprintf(&buf, "\n[limits]\n\nreject = true\n")
+	}
+	writeLimit(&buf, "limits.x-axis", t.Limits.AxisX)
+	writeLimit(&buf, "limits.y-axis", t.Limits.AxisY)
+	writeLimit(&buf, "limits.z-axis", t.Limits.AxisZ)
+	return buf.WriteTo(w)
+}
+
+func writeXYZ(w io.Writer, name string, v XYZ) {
+	fmt.Fprintf(w, "\n[%s]\n\n", name)
+	fmt.Fprintf(w, "X = %s\n", formatFloat(v.X))
+	fmt.Fprintf(w, "Y = %s\n", formatFloat(v.Y))
+	fmt.Fprintf(w, "Z = %s\n", formatFloat(v.Z))
+}
+
+func writeABC(w io.Writer, name string, a ABC) {
+	fmt.Fprintf(w, "\n[%s]\n\n", name)
+	fmt.Fprintf(w, "A0 = %s\n", formatFloat(a.A0))
+	fmt.Fprintf(w, "A1 = %s\n", formatFloat(a.A1))
+	fmt.Fprintf(w, "B0 = %s\n", formatFloat(a.B0))
+	fmt.Fprintf(w, "B1 = %s\n", formatFloat(a.B1))
+	fmt.Fprintf(w, "B2 = %s\n", formatFloat(a.B2))
+	fmt.Fprintf(w, "B3 = %s\n", formatFloat(a.B3))
+	fmt.Fprintf(w, "B4 = %s\n", formatFloat(a.B4))
+	fmt.Fprintf(w, "C0 = %s\n", formatFloat(a.C0))
+	fmt.Fprintf(w, "C1 = %s\n", formatFloat(a.C1))
+	fmt.Fprintf(w, "C2 = %s\n", formatFloat(a.C2))
+	fmt.Fprintf(w, "C3 = %s\n", formatFloat(a.C3))
+	fmt.Fprintf(w, "C4 = %s\n", formatFloat(a.C4))
+}
+
+func writeAlignment(w io.Writer, a Alignment) {
+	if len(a.Matrix) == 0 && a.Roll == 0 && a.Pitch == 0 && a.Yaw == 0 && a.Lever == (XYZ{}) && a.Rate == (XYZ{}) {
+		return
+	}
+	fmt.Fprintf(w, "\n[alignment]\n\n")
+	if len(a.Matrix) > 0 {
+		var rows []string
+		for _, r := range a.Matrix {
+			rows = append(rows, formatRange(r))
+		}
+		fmt.Fprintf(w, "matrix = [%s]\n", strings.Join(rows, ", "))
+	}
+	fmt.Fprintf(w, "roll = %s\n", formatFloat(a.Roll))
+	fmt.Fprintf(w, "pitch = %s\n", formatFloat(a.Pitch))
+	fmt.Fprintf(w, "yaw = %s\n", formatFloat(a.Yaw))
+	writeXYZ(w, "alignment.lever", a.Lever)
+	writeXYZ(w, "alignment.rate", a.Rate)
+}
+
+func writeLimit(w io.Writer, name string, i Limit) {
+	if len(i.Temperature) == 0 && len(i.Acceleration) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n[%s]\n\n", name)
+	if len(i.Temperature) > 0 {
+		fmt.Fprintf(w, "temperature = %s\n", formatRange(i.Temperature))
+	}
+	if len(i.Acceleration) > 0 {
+		fmt.Fprintf(w, "acceleration = %s\n", formatRange(i.Acceleration))
+	}
+}
+
+func formatRange(r []float64) string {
+	var vs []string
+	for _, v := range r {
+		vs = append(vs, formatFloat(v))
+	}
+	return "[" + strings.Join(vs, ", ") + "]"
+}
+
+func formatFloat(v float64) string {
+	str := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(str, ".eEnN") {
+		str += ".0"
+	}
+	return str
+}
+
+// Reference gives the reference temperatures and the zero points of each axis.
+// When a table file does not give them, TempZero is used for the zero point
+// and TempMMA minus the zero point for the reference temperature.
+func (t Table) Reference() (ref, zero XYZ) {
+	return t.Calib, t.Zero
+}
+
 func (t Table) SampleFrequency() float64 {
 	return 1 / float64(t.Frequency)
 }
@@ -223,52 +428,90 @@ func (t *Table) TempOffsetZ(a float64) float64 {
 }
 
 func (t *Table) Calibrate(file string) ([]Measurement, error) {
-	raw, err := Convert(file, false)
-	if err != nil {
+	return t.CalibrateWith(file, Options{})
+}
+
+func (t *Table) CalibrateWith(file string, opt Options) ([]Measurement, error) {
+	opt = opt.withFrequency(t.Frequency)
+	raw, err := ConvertWith(file, opt)
+	if err != nil && (!opt.Partial || len(raw) == 0) {
 		return nil, err
 	}
-	var (
-		ms  []Measurement
-		upi = splitFile(file)
-	)
-	for i := 0; i < len(raw); i++ {
-		m := t.calibrate(raw[i])
-		m.UPI = upi
-		ms = append(ms, m)
+	ms, e := t.calibrateAll(raw, splitFile(file), opt)
+	if e != nil {
+		return nil, e
 	}
-	return ms, nil
+	return ms, err
 }
 
-func (t *Table) calibrate(rec Record) Measurement {
-	m := rec.Measurement()
-
-	// temperatures in micro ampere (micXXX) and celsius (celXXX)
-	m.MicX, m.DegX = t.AxisX.Temperatures(float64(m.Raw[0]))
-	m.MicY, m.DegY = t.AxisY.Temperatures(float64(m.Raw[1]))
-	m.MicZ, m.DegZ = t.AxisZ.Temperatures(float64(m.Raw[2]))
-
-	// compute Ai
-	var (
-		ax = m.MicX + TempDelta
-		ay = m.MicY + TempDelta
-		az = m.MicZ + TempDelta
-	)
-
-	// compute Scale factor
-	m.ScaleX = t.ScaleFactorX(ax)
-	m.ScaleY = t.ScaleFactorY(ay)
-	m.ScaleZ = t.ScaleFactorZ(az)
+func (t *Table) CalibrateReader(r io.Reader, upi string, opt Options) ([]Measurement, error) {
+	opt = opt.withFrequency(t.Frequency)
+	raw, err := ConvertReader(r, opt)
+	if err != nil && (!opt.Partial || len(raw) == 0) {
+		return nil, err
+	}
+	ms, e := t.calibrateAll(raw, upi, opt)
+	if e != nil {
+		return nil, e
+	}
+	return ms, err
+}
 
-	// compute offset temperature
-	m.OffsetX = t.TempOffsetX(ax)
-	m.OffsetY = t.TempOffsetY(ay)
-	m.OffsetZ = t.TempOffsetZ(az)
+func (t *Table) CalibrateMerge(realtime, playback string, opt Options) ([]Measurement, error) {
+	opt = opt.withFrequency(t.Frequency)
+	raw, err := MergeWith(realtime, playback, opt)
+	if err != nil && (!opt.Partial || len(raw) == 0) {
+		return nil, err
+	}
+	ms, e := t.calibrateAll(raw, splitFile(mergeName(realtime, playback)), opt)
+	if e != nil {
+		return nil, e
+	}
+	return ms, err
+}
 
-	m.AccX = apply(pick(m.Raw, 4), m.ScaleX, m.OffsetX)
-	m.AccY = apply(pick(m.Raw, 5), m.ScaleY, m.OffsetY)
-	m.AccZ = apply(pick(m.Raw, 6), m.ScaleZ, m.OffsetZ)
+func (t *Table) calibrateAll(raw []Record, upi string, opt Options) ([]Measurement, error) {
+	c, err := t.Calibrator()
+	if err != nil {
+		return nil, err
+	}
+	for i := range raw {
+		raw[i].Flags |= t.Limits.checkRaw(*t, raw[i])
+	}
+	opt.Filter.Apply(raw, opt.timeline().Step())
 
-	return m
+	var ms []Measurement
+	for i := 0; i < len(raw); i++ {
+		m := c.Calibrate(raw[i])
+		m.UPI = upi
+		m.SatX, m.SatY, m.SatZ = raw[i].Saturation()
+		if opt.Sigma {
+			if p, ok := c.(Propagator); ok {
+				p.Propagate(&m)
+			} else {
+				m.SigmaDegX, m.SigmaDegY, m.SigmaDegZ = math.NaN(), math.NaN(), math.NaN()
+			}
+		}
+		if err := t.Align.Align(&m); err != nil {
+			return nil, err
+		}
+		q, vs := t.Limits.Check(m)
+		if len(vs) > 0 && opt.Log != nil {
+			action := "flagged"
+			if t.Limits.Reject {
+				action = "rejected"
+			}
+			for _, v := range vs {
+				opt.Log.Printf("%s: vmu %d, sequence %d: %s: %s", upi, m.Vid, m.Seq, action, v)
+			}
+		}
+		if len(vs) > 0 && t.Limits.Reject {
+			continue
+		}
+		m.Flags |= q
+		ms = append(ms, m)
+	}
+	return ms, nil
 }
 
 func Calibrate(file string) ([]Measurement, error) {
@@ -280,7 +523,49 @@ type Record struct {
 	Vid    uint32
 	When   time.Time
 	Raw    []int16
+	Status Status
 	NoDate bool
+	// sequence counter unwrapped on the timeline
+	Count  int64
+	Reason Reason
+	// raw temperatures smoothed by a Filter
+	Temp []float64
+	// quality flags of the record
+	Flags Quality
+	// copies of the file in which the record was found when merged
+	Source Source
+}
+
+// Status is the status/housekeeping word found in the fourth raw value of a
+// record.
+type Status uint16
+
+const StatusIndex = 3
+
+func (s Status) Has(bit int) bool {
+	return bit >= 0 && bit < 16 && s&(1<<bit) != 0
+}
+
+func (s Status) Flags() []int {
+	var bits []int
+	for i := 0; i < 16; i++ {
+		if s.Has(i) {
+			bits = append(bits, i)
+		}
+	}
+	return bits
+}
+
+func (s Status) Bits() string {
+	var str []string
+	for _, b := range s.Flags() {
+		str = append(str, strconv.Itoa(b))
+	}
+	return strings.Join(str, "|")
+}
+
+func (s Status) String() string {
+	return fmt.Sprintf("0x%04x", uint16(s))
 }
 
 func (r Record) Measurement() Measurement {
@@ -289,78 +574,166 @@ func (r Record) Measurement() Measurement {
 	}
 }
 
+// Temperatures gives the raw temperatures of the record, smoothed if a Filter
+// has been applied.
+func (r Record) Temperatures() (x, y, z float64) {
+	if len(r.Temp) == 3 {
+		return r.Temp[0], r.Temp[1], r.Temp[2]
+	}
+	return float64(r.Raw[0]), float64(r.Raw[1]), float64(r.Raw[2])
+}
+
+// Saturated reports whether a raw acceleration count is at the limits of its
+// range.
+func Saturated(v int16) bool {
+	return v >= MaxValue-1 || v <= -MaxValue
+}
+
+// Saturation gives, for each sample of the record, whether the raw count of
+// each axis is saturated.
+func (r Record) Saturation() (x, y, z []bool) {
+	for i := 4; i+2 < len(r.Raw); i += 3 {
+		x = append(x, Saturated(r.Raw[i]))
+		y = append(y, Saturated(r.Raw[i+1]))
+		z = append(z, Saturated(r.Raw[i+2]))
+	}
+	return
+}
+
+// Saturated gives the number of samples of the record having at least one
+// saturated axis.
+func (r Record) Saturated() int {
+	var n int
+	x, y, z := r.Saturation()
+	for i := range x {
+		if x[i] || y[i] || z[i] {
+			n++
+		}
+	}
+	return n
+}
+
+// IsSaturated reports whether one of the axes of the i-th sample is saturated.
+func (m Measurement) IsSaturated(i int) bool {
+	return (i < len(m.SatX) && m.SatX[i]) || (i < len(m.SatY) && m.SatY[i]) || (i < len(m.SatZ) && m.SatZ[i])
+}
+
+func (r Record) Checksum() uint32 {
+	return adler32.Checksum(marshalRecord(r))
+}
+
+type Options struct {
+	// keep records having the same checksum
+	Duplicate bool
+	// return the records decoded before an error with the error
+	Partial bool
+	// skip records already seen in previous files (records are added to the
+	// index by the caller once written)
+	Index *Index
+	// order records on a timeline shared by multiple files
+	Timeline *Timeline
+	// time scale of the acquisition times
+	Scale TimeScale
+	// smooth the raw temperatures of consecutive records before their
+	// calibration
+	Filter *Filter
+	// keep the records already found in Index and mark them as duplicate
+	Mark bool
+	// log the records flagged or rejected by the limits of the table
+	Log *log.Logger
+	// compute the standard uncertainties of the measurements
+	Sigma bool
+}
+
 func Convert(file string, duplicate bool) ([]Record, error) {
-	mma, err := Open(file)
+	return ConvertWith(file, Options{Duplicate: duplicate})
+}
+
+func ConvertWith(file string, opt Options) ([]Record, error) {
+	r, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
+	defer r.Close()
+
+	dec := NewDecoder(r)
+	dec.Name = file
+	dec.Scale = opt.Scale
+	return convert(dec, opt)
+}
+
+func ConvertReader(r io.Reader, opt Options) ([]Record, error) {
+	dec := NewDecoder(r)
+	dec.Scale = opt.Scale
+	return convert(dec, opt)
+}
+
+func convert(dec *Decoder, opt Options) ([]Record, error) {
+	data, err := decode(dec, opt)
+	if err != nil && (!opt.Partial || data == nil) {
+		return nil, err
+	}
+	return opt.finish(data), err
+}
+
+func decode(dec *Decoder, opt Options) ([]Record, error) {
 	var (
-		sum  = adler32.New()
-		rs   = bytes.NewReader(mma.Raw)
-		tee  = io.TeeReader(rs, sum)
 		seen = make(map[uint32]struct{})
 		data []Record
 	)
-	for i := 0; rs.Len() > 0; i++ {
-		var (
-			raw [31]int16
-			rec Record
-		)
-		if err := binary.Read(tee, binary.BigEndian, &rec.Seq); err != nil {
-			return nil, err
+	if _, err := dec.Header(); err != nil {
+		return nil, err
+	}
+	for {
+		rec, err := dec.Decode()
+		if err == io.EOF {
+			break
 		}
-		if err := binary.Read(tee, binary.BigEndian, &raw); err != nil {
-			return nil, err
+		if err != nil {
+			return data, err
+		}
+		if !opt.Duplicate && opt.Index != nil && opt.Index.Has(rec) {
+			if !opt.Mark {
+				continue
+			}
+			rec.Flags |= QualityDuplicate
 		}
-		cksum := sum.Sum32()
-		if _, ok := seen[cksum]; duplicate || !ok {
-			rec.Raw = append(rec.Raw, raw[:]...)
-			rec.When = mma.When
-			rec.Vid = mma.Vid
-			data = insertRecord(data, rec)
+		cksum := rec.Checksum()
+		_, ok := seen[cksum]
+		if ok {
+			rec.Flags |= QualityDuplicate
+		}
+		if opt.Duplicate || !ok {
+			data = append(data, rec)
 			seen[cksum] = struct{}{}
 		}
-		sum.Reset()
 	}
 	return data, nil
 }
 
-const (
-	AvgCount = 219
-	MinDelta = -AvgCount * MeasCount
-	MaxDelta = AvgCount * MeasCount
-)
+func (o Options) finish(data []Record) []Record {
+	return o.timeline().Order(data)
+}
 
-func insertRecord(data []Record, rec Record) []Record {
-	z := len(data)
-	if z == 0 {
-		data = append(data, rec)
-		return data
+func (o Options) timeline() *Timeline {
+	if o.Timeline == nil {
+		return NewTimeline(0)
 	}
-	for i := z - 1; i >= 0; i-- {
-		if data[i].NoDate {
-			break
-		}
-		diff := int16(rec.Seq) - int16(data[i].Seq)
-		if len(data) == 4 && (diff < MinDelta || diff > MaxDelta) {
-			// data = append(make([]Record, 0, len(data)), rec)
-			for i := 0; i < len(data); i++ {
-				data[i].NoDate = true
-			}
-			data = append(data, rec)
-			return data
-		}
-		if diff >= 0 {
-			data = append(data[:i+1], append([]Record{rec}, data[i+1:]...)...)
-			return data
-		}
+	return o.Timeline
+}
+
+// withFrequency gives a copy of the options with a Timeline for a sensor
+// sampling at freq Hz if none is set.
+func (o Options) withFrequency(freq int64) Options {
+	if o.Timeline == nil {
+		o.Timeline = NewTimeline(freq)
 	}
-	data = append([]Record{rec}, data...)
-	return data
+	return o
 }
 
 type MMA struct {
-	Raw  []byte
+	Raw []byte
+	// acquisition time in GPS time
 	When time.Time
 	Vid  uint32
 }
@@ -373,22 +746,17 @@ func Open(file string) (MMA, error) {
 	}
 	defer r.Close()
 
-	hdr := struct {
-		FCC  [4]byte
-		Seq  uint32
-		Time int64
-	}{}
-	if err := binary.Read(r, binary.BigEndian, &hdr); err != nil {
+	dec := NewDecoder(r)
+	dec.Name = file
+	hdr, err := dec.Header()
+	if err != nil {
 		return m, err
 	}
-	if !bytes.Equal(hdr.FCC[:], Magic) {
-		return m, fmt.Errorf("%s: invalid FCC", string(hdr.FCC[:]))
-	}
-	if m.Raw, err = ioutil.ReadAll(r); err != nil {
+	if m.Raw, err = ioutil.ReadAll(dec.reader); err != nil {
 		return m, err
 	}
-	m.When = Epoch.Add(time.Duration(hdr.Time))
-	m.Vid = hdr.Seq
+	m.When = hdr.When
+	m.Vid = hdr.Vid
 	return m, err
 }
 
@@ -410,10 +778,9 @@ func pick(values []int16, n int) []float64 {
 }
 
 func splitFile(file string) string {
-	parts := strings.Split(filepath.Base(file), "_")
-	z := len(parts)
-	if z == 0 {
+	fn, err := ParseFilename(file)
+	if err != nil {
 		return ""
 	}
-	return strings.Join(parts[1:z-5], "_")
+	return fn.UPI
 }
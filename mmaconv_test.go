@@ -0,0 +1,53 @@
+package mmaconv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTableReference(t *testing.T) {
+	data := []struct {
+		Name string
+		Toml string
+		Ref  XYZ
+		Zero XYZ
+	}{
+		{
+			Name: "missing",
+			Toml: "frequency = 1500\n",
+			Ref:  XYZ{X: 293, Y: 293, Z: 293},
+			Zero: XYZ{X: TempZero, Y: TempZero, Z: TempZero},
+		},
+		{
+			Name: "zero only",
+			Toml: "frequency = 1500\n[zero]\nX = -270\nY = 0\n",
+			Ref:  XYZ{X: 290, Y: TempMMA, Z: 293},
+			Zero: XYZ{X: -270, Y: 0, Z: TempZero},
+		},
+		{
+			Name: "both",
+			Toml: "frequency = 1500\n[calibration]\nX = 0\nY = 25\nZ = 300\n[zero]\nX = 0\nY = 0\nZ = -273.15\n",
+			Ref:  XYZ{X: 0, Y: 25, Z: 300},
+			Zero: XYZ{X: 0, Y: 0, Z: -273.15},
+		},
+	}
+	dir := t.TempDir()
+	for i, d := range data {
+		file := filepath.Join(dir, d.Name+".toml")
+		if err := os.WriteFile(file, []byte(d.Toml), 0644); err != nil {
+			t.Fatal(err)
+		}
+		tbl := DefaultTable
+		if i%2 == 1 {
+			tbl = Table{}
+		}
+		if err := tbl.Decode(file); err != nil {
+			t.Fatalf("%s: unexpected error: %s", d.Name, err)
+		}
+		ref, zero := tbl.Reference()
+		if ref != d.Ref || zero != d.Zero {
+			t.Errorf("%s: want %v/%v, got %v/%v", d.Name, d.Ref, d.Zero, ref, zero)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package mmaconv
+
+import "fmt"
+
+const DefaultModel = "polynomial"
+
+// Calibrator converts the raw values of a record into temperatures and
+// accelerations.
+type Calibrator interface {
+	Calibrate(rec Record) Measurement
+}
+
+// Propagator is implemented by the Calibrators able to give the standard
+// uncertainties of the measurements they compute.
+type Propagator interface {
+	Propagate(m *Measurement)
+}
+
+// ModelFunc creates the Calibrator of a model from the parameters of a table.
+type ModelFunc func(Table) (Calibrator, error)
+
+var models = map[string]ModelFunc{
+	DefaultModel: func(t Table) (Calibrator, error) {
+		return Polynomial{table: t}, nil
+	},
+}
+
+// RegisterModel makes a model available to tables under the given name.
+func RegisterModel(name string, fn ModelFunc) {
+	models[name] = fn
+}
+
+// Calibrator gives the Calibrator of the model selected by the table.
+func (t Table) Calibrator() (Calibrator, error) {
+	name := t.Model
+	if name == "" {
+		name = DefaultModel
+	}
+	fn, ok := models[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown model", name)
+	}
+	return fn(t)
+}
+
+// Polynomial is the default model: temperatures are computed from A0/A1 and
+// accelerations are compensated with the polynomials B1..B4 (offset) and
+// C0..C4 (scale factor) of each axis.
+type Polynomial struct {
+	table Table
+}
+
+func (p Polynomial) Calibrate(rec Record) Measurement {
+	var (
+		t         = p.table
+		m         = rec.Measurement()
+		ref, zero = t.Reference()
+	)
+	m.RefX, m.RefY, m.RefZ = ref.X, ref.Y, ref.Z
+
+	// temperatures in micro ampere (micXXX) and celsius (celXXX)
+	tx, ty, tz := rec.Temperatures()
+	m.MicX, m.DegX = t.AxisX.TemperaturesAt(tx, ref.X+zero.X)
+	m.MicY, m.DegY = t.AxisY.TemperaturesAt(ty, ref.Y+zero.Y)
+	m.MicZ, m.DegZ = t.AxisZ.TemperaturesAt(tz, ref.Z+zero.Z)
+	_, m.RawDegX = t.AxisX.TemperaturesAt(float64(m.Raw[0]), ref.X+zero.X)
+	_, m.RawDegY = t.AxisY.TemperaturesAt(float64(m.Raw[1]), ref.Y+zero.Y)
+	_, m.RawDegZ = t.AxisZ.TemperaturesAt(float64(m.Raw[2]), ref.Z+zero.Z)
+
+	// compute Ai
+	var (
+		ax = m.MicX - ref.X
+		ay = m.MicY - ref.Y
+		az = m.MicZ - ref.Z
+	)
+
+	// compute Scale factor
+	m.ScaleX = t.ScaleFactorX(ax)
+	m.ScaleY = t.ScaleFactorY(ay)
+	m.ScaleZ = t.ScaleFactorZ(az)
+
+	// compute offset temperature
+	m.OffsetX = t.TempOffsetX(ax)
+	m.OffsetY = t.TempOffsetY(ay)
+	m.OffsetZ = t.TempOffsetZ(az)
+
+	m.AccX = apply(pick(m.Raw, 4), m.ScaleX, m.OffsetX)
+	m.AccY = apply(pick(m.Raw, 5), m.ScaleY, m.OffsetY)
+	m.AccZ = apply(pick(m.Raw, 6), m.ScaleZ, m.OffsetZ)
+
+	return m
+}
+
+// Propagate sets the standard uncertainties of a measurement computed by
+// Calibrate.
+func (p Polynomial) Propagate(m *Measurement) {
+	cs := p.table.channels()
+	m.SigmaDegX = cs[0].temperature(m.MicX)
+	m.SigmaDegY = cs[1].temperature(m.MicY)
+	m.SigmaDegZ = cs[2].temperature(m.MicZ)
+	m.SigmaAccX = cs[0].accelerations(pick(m.Raw, 4), m.MicX-m.RefX)
+	m.SigmaAccY = cs[1].accelerations(pick(m.Raw, 5), m.MicY-m.RefY)
+	m.SigmaAccZ = cs[2].accelerations(pick(m.Raw, 6), m.MicZ-m.RefZ)
+}
@@ -0,0 +1,66 @@
+package mmaconv
+
+import "strings"
+
+// Quality is a bitmask of the conditions affecting the quality of a sample.
+type Quality uint16
+
+const (
+	// no date can be given to the sample
+	QualityNoDate Quality = 1 << iota
+	// too many records in the file to date them
+	QualityIndatable
+	// record already found in the same file or in a previous file
+	QualityDuplicate
+	// records are missing before the record
+	QualityGap
+	// raw count of at least one axis is saturated
+	QualitySaturated
+	// temperature of at least one axis is outside the limits of the table
+	QualityTemperature
+	// file is outside the periods of activities
+	QualitySchedule
+	// acceleration of at least one axis is outside the limits of the table
+	QualityAcceleration
+)
+
+var qualityNames = []string{
+	"nodate",
+	"indatable",
+	"duplicate",
+	"gap",
+	"saturated",
+	"temperature",
+	"schedule",
+	"acceleration",
+}
+
+func (q Quality) Has(flag Quality) bool {
+	return q&flag != 0
+}
+
+func (q Quality) String() string {
+	var str []string
+	for i, n := range qualityNames {
+		if q.Has(1 << i) {
+			str = append(str, n)
+		}
+	}
+	return strings.Join(str, "|")
+}
+
+// Quality gives the quality flags of the i-th sample of the measurement. A gap
+// is only reported for the first sample of a record.
+func (m Measurement) Quality(i int) Quality {
+	q := m.Flags
+	if i > 0 {
+		q &^= QualityGap
+	}
+	if m.NoDate {
+		q |= QualityNoDate
+	}
+	if m.IsSaturated(i) {
+		q |= QualitySaturated
+	}
+	return q
+}
@@ -0,0 +1,189 @@
+package mmaconv
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+)
+
+const scanBufferSize = 32 << 10
+
+// MaxHeaderDelta is the largest difference between the acquisition times of
+// a segment and of the next one. A marker found in the records of a segment
+// whose header is further away is taken as sample data.
+const MaxHeaderDelta = 24 * time.Hour
+
+type Segment struct {
+	Header
+	// offset of the magic marker in the stream
+	Offset int64
+	// number of bytes skipped since the end of the previous segment
+	Skipped int64
+	Records []Record
+}
+
+type Scanner struct {
+	Scale TimeScale
+
+	reader  io.Reader
+	buffer  []byte
+	offset  int64
+	skipped int64
+	segment Segment
+	eof     bool
+	err     error
+
+	// position in the buffer of the header of the next segment, -1 if it
+	// has to be searched
+	next int
+}
+
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{
+		reader: r,
+		buffer: make([]byte, 0, scanBufferSize),
+		next:   -1,
+	}
+}
+
+func ScanFile(file string) ([]Segment, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var (
+		scan = NewScanner(r)
+		segs []Segment
+	)
+	for scan.Scan() {
+		segs = append(segs, scan.Segment())
+	}
+	return segs, scan.Err()
+}
+
+func (s *Scanner) Segment() Segment {
+	return s.segment
+}
+
+// Skipped gives the total number of bytes that do not belong to any segment.
+func (s *Scanner) Skipped() int64 {
+	return s.skipped
+}
+
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	var skip int64
+	for {
+		ix := s.next
+		if ix < 0 {
+			ix = bytes.Index(s.buffer, Magic)
+		}
+		s.next = -1
+		if ix >= 0 {
+			skip += int64(ix)
+			s.discard(ix)
+			break
+		}
+		if s.eof {
+			skip += int64(len(s.buffer))
+			s.discard(len(s.buffer))
+			s.skipped += skip
+			return false
+		}
+		if n := len(s.buffer) - len(Magic) + 1; n > 0 {
+			skip += int64(n)
+			s.discard(n)
+		}
+		s.fill()
+	}
+	s.skipped += skip
+
+	if !s.need(HeaderLen) {
+		s.skipped += int64(len(s.buffer))
+		s.discard(len(s.buffer))
+		return false
+	}
+	s.segment = Segment{
+		Header:  unmarshalHeader(s.buffer, s.Scale),
+		Offset:  s.offset,
+		Skipped: skip,
+	}
+	s.discard(HeaderLen)
+	for s.need(RecordLen) {
+		if ix := s.header(); ix >= 0 {
+			s.next = ix
+			return true
+		}
+		s.segment.Records = append(s.segment.Records, unmarshalRecord(s.buffer, s.segment.Header))
+		s.discard(RecordLen)
+	}
+	return s.err == nil
+}
+
+// header gives the position of the header of the next segment if one starts
+// before the end of the next record. Sample data can contain the marker: only
+// the headers whose acquisition time is close to the one of the current
+// segment are kept.
+func (s *Scanner) header() int {
+	var (
+		size = RecordLen + len(Magic) - 1
+		from int
+	)
+	for {
+		n := from + size
+		if n > len(s.buffer) {
+			n = len(s.buffer)
+		}
+		ix := bytes.Index(s.buffer[from:n], Magic)
+		if ix < 0 || from+ix >= RecordLen {
+			return -1
+		}
+		ix += from
+		if !s.need(ix + HeaderLen) {
+			return ix
+		}
+		hdr := unmarshalHeader(s.buffer[ix:], s.Scale)
+		if delta := hdr.When.Sub(s.segment.When); delta >= -MaxHeaderDelta && delta <= MaxHeaderDelta {
+			return ix
+		}
+		from = ix + 1
+	}
+}
+
+func (s *Scanner) need(n int) bool {
+	for len(s.buffer) < n && !s.eof {
+		s.fill()
+	}
+	return len(s.buffer) >= n
+}
+
+func (s *Scanner) fill() {
+	if len(s.buffer) == cap(s.buffer) {
+		size := scanBufferSize
+		if len(s.buffer) >= size/2 {
+			size = 2 * len(s.buffer)
+		}
+		s.buffer = append(make([]byte, 0, size), s.buffer...)
+	}
+	n, err := s.reader.Read(s.buffer[len(s.buffer):cap(s.buffer)])
+	s.buffer = s.buffer[:len(s.buffer)+n]
+	if err == io.EOF {
+		s.eof = true
+	} else if err != nil {
+		s.eof, s.err = true, err
+	}
+}
+
+func (s *Scanner) discard(n int) {
+	s.buffer = s.buffer[n:]
+	s.offset += int64(n)
+}
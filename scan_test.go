@@ -0,0 +1,151 @@
+package mmaconv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+var scanEpoch = time.Date(2021, 5, 29, 10, 10, 10, 0, time.UTC)
+
+// appendSegment appends the header and the records of a file. The raw values
+// of each record are taken from raws, the missing ones are set to zero.
+func appendSegment(buf []byte, vid uint32, when time.Time, seq uint16, raws ...[]int16) []byte {
+	hdr := make([]byte, HeaderLen)
+	copy(hdr, Magic)
+	binary.BigEndian.PutUint32(hdr[4:], vid)
+	binary.BigEndian.PutUint64(hdr[8:], uint64(when.Sub(Epoch)))
+	buf = append(buf, hdr...)
+	for i, raw := range raws {
+		rec := make([]byte, RecordLen)
+		binary.BigEndian.PutUint16(rec, seq+uint16(i*MeasCount))
+		for j, v := range raw {
+			binary.BigEndian.PutUint16(rec[2+j*2:], uint16(v))
+		}
+		buf = append(buf, rec...)
+	}
+	return buf
+}
+
+func makeRaws(n int) [][]int16 {
+	raws := make([][]int16, n)
+	for i := range raws {
+		raws[i] = []int16{10073, 10286, 9930, int16(i), -437, -243, -52}
+	}
+	return raws
+}
+
+type scanWant struct {
+	Vid     uint32
+	Offset  int64
+	Skipped int64
+	Records int
+}
+
+func checkScan(t *testing.T, buf []byte, skipped int64, want []scanWant) []Segment {
+	t.Helper()
+	var (
+		scan = NewScanner(bytes.NewReader(buf))
+		segs []Segment
+	)
+	for scan.Scan() {
+		segs = append(segs, scan.Segment())
+	}
+	if err := scan.Err(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(segs) != len(want) {
+		t.Fatalf("segments: want %d, got %d", len(want), len(segs))
+	}
+	for i, w := range want {
+		g := segs[i]
+		if g.Vid != w.Vid || g.Offset != w.Offset || g.Skipped != w.Skipped || len(g.Records) != w.Records {
+			t.Errorf("segment %d: want %d/%d/%d/%d, got %d/%d/%d/%d", i, w.Vid, w.Offset, w.Skipped, w.Records, g.Vid, g.Offset, g.Skipped, len(g.Records))
+		}
+	}
+	if got := scan.Skipped(); got != skipped {
+		t.Errorf("skipped: want %d, got %d", skipped, got)
+	}
+	return segs
+}
+
+func TestScanConcatenated(t *testing.T) {
+	var buf []byte
+	buf = appendSegment(buf, 1, scanEpoch, 100, makeRaws(4)...)
+	buf = appendSegment(buf, 2, scanEpoch.Add(time.Second), 136, makeRaws(3)...)
+	buf = appendSegment(buf, 3, scanEpoch.Add(2*time.Second), 163, makeRaws(2)...)
+
+	segs := checkScan(t, buf, 0, []scanWant{
+		{Vid: 1, Offset: 0, Records: 4},
+		{Vid: 2, Offset: HeaderLen + 4*RecordLen, Records: 3},
+		{Vid: 3, Offset: 2*HeaderLen + 7*RecordLen, Records: 2},
+	})
+	if got := segs[1].When; !got.Equal(scanEpoch.Add(time.Second)) {
+		t.Errorf("time of second segment: want %s, got %s", scanEpoch.Add(time.Second), got)
+	}
+}
+
+func TestScanGarbage(t *testing.T) {
+	var (
+		head = []byte("garbage before the first file")
+		junk = bytes.Repeat([]byte{0xff}, 40)
+		buf  []byte
+	)
+	buf = append(buf, head...)
+	buf = appendSegment(buf, 1, scanEpoch, 100, makeRaws(4)...)
+	buf = append(buf, junk...)
+	buf = appendSegment(buf, 2, scanEpoch.Add(time.Second), 136, makeRaws(3)...)
+	buf = append(buf, Magic[:2]...)
+
+	var (
+		first  = int64(len(head))
+		second = first + HeaderLen + 4*RecordLen + int64(len(junk))
+	)
+	checkScan(t, buf, int64(len(head)+len(junk)+2), []scanWant{
+		{Vid: 1, Offset: first, Skipped: int64(len(head)), Records: 4},
+		{Vid: 2, Offset: second, Skipped: int64(len(junk)), Records: 3},
+	})
+}
+
+func TestScanMarkerInRecord(t *testing.T) {
+	data := []struct {
+		Name string
+		Seq  uint16
+		Raw  []int16
+	}{
+		{
+			Name: "inside record",
+			Seq:  109,
+			Raw:  []int16{10073, 19789, 16672, 0x1234, -437, -243, -52},
+		},
+		{
+			Name: "record boundary",
+			Seq:  0x4d4d,
+			Raw:  []int16{16672, 10286, 9930, 0x1234, -437, -243, -52},
+		},
+	}
+	for _, d := range data {
+		var (
+			raws = makeRaws(4)
+			buf  []byte
+		)
+		raws[1] = d.Raw
+		buf = appendSegment(buf, 1, scanEpoch, d.Seq-MeasCount, raws...)
+		buf = appendSegment(buf, 2, scanEpoch.Add(time.Second), 136, makeRaws(2)...)
+
+		segs := checkScan(t, buf, 0, []scanWant{
+			{Vid: 1, Offset: 0, Records: 4},
+			{Vid: 2, Offset: HeaderLen + 4*RecordLen, Records: 2},
+		})
+		rec := segs[0].Records[1]
+		if rec.Seq != d.Seq {
+			t.Errorf("%s: sequence: want %d, got %d", d.Name, d.Seq, rec.Seq)
+		}
+		for i, v := range d.Raw {
+			if rec.Raw[i] != v {
+				t.Errorf("%s: raw %d: want %d, got %d", d.Name, i, v, rec.Raw[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package mmaconv
+
+import (
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/midbel/toml"
+)
+
+// TablePeriod is a parameters table with the period during which it is valid.
+// A zero Starts or Ends leaves the period open on that side.
+type TablePeriod struct {
+	Starts time.Time
+	Ends   time.Time
+	File   string
+	Table  Table `toml:"-"`
+}
+
+func (p TablePeriod) IsValid(t time.Time) bool {
+	if !p.Starts.IsZero() && t.Before(p.Starts) {
+		return false
+	}
+	if !p.Ends.IsZero() && t.After(p.Ends) {
+		return false
+	}
+	return true
+}
+
+// TableSet selects the parameters table to use for a file from its acquisition
+// time. Default is used when no period matches.
+type TableSet struct {
+	Default Table         `toml:"-"`
+	Periods []TablePeriod `toml:"table"`
+}
+
+func NewTableSet(tbl Table) TableSet {
+	return TableSet{
+		Default: tbl,
+	}
+}
+
+// Set loads the periods of the set and their tables. Files of the tables are
+// relative to the directory of the set file.
+func (s *TableSet) Set(file string) error {
+	if err := toml.DecodeFile(file, s); err != nil {
+		return err
+	}
+	dir := filepath.Dir(file)
+	for i, p := range s.Periods {
+		if !filepath.IsAbs(p.File) {
+			p.File = filepath.Join(dir, p.File)
+		}
+		p.Table = s.Default
+		if err := p.Table.Set(p.File); err != nil {
+			return err
+		}
+		s.Periods[i] = p
+	}
+	return nil
+}
+
+func (s *TableSet) String() string {
+	return "parameters tables set file"
+}
+
+func (s *TableSet) Find(acq time.Time) Table {
+	for _, p := range s.Periods {
+		if p.IsValid(acq) {
+			return p.Table
+		}
+	}
+	return s.Default
+}
+
+func (s *TableSet) Calibrate(file string) ([]Measurement, error) {
+	return s.CalibrateWith(file, Options{})
+}
+
+func (s *TableSet) CalibrateWith(file string, opt Options) ([]Measurement, error) {
+	opt = opt.withFrequency(s.Default.Frequency)
+	raw, err := ConvertWith(file, opt)
+	if len(raw) == 0 || (err != nil && !opt.Partial) {
+		return nil, err
+	}
+	tbl := s.Find(raw[0].When)
+	ms, e := tbl.calibrateAll(raw, splitFile(file), opt)
+	if e != nil {
+		return nil, e
+	}
+	return ms, err
+}
+
+func (s *TableSet) CalibrateMerge(realtime, playback string, opt Options) ([]Measurement, error) {
+	opt = opt.withFrequency(s.Default.Frequency)
+	raw, err := MergeWith(realtime, playback, opt)
+	if len(raw) == 0 || (err != nil && !opt.Partial) {
+		return nil, err
+	}
+	tbl := s.Find(raw[0].When)
+	ms, e := tbl.calibrateAll(raw, splitFile(mergeName(realtime, playback)), opt)
+	if e != nil {
+		return nil, e
+	}
+	return ms, err
+}
+
+func (s *TableSet) CalibrateReader(r io.Reader, upi string, opt Options) ([]Measurement, error) {
+	opt = opt.withFrequency(s.Default.Frequency)
+	raw, err := ConvertReader(r, opt)
+	if len(raw) == 0 || (err != nil && !opt.Partial) {
+		return nil, err
+	}
+	tbl := s.Find(raw[0].When)
+	ms, e := tbl.calibrateAll(raw, upi, opt)
+	if e != nil {
+		return nil, e
+	}
+	return ms, err
+}
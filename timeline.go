@@ -0,0 +1,186 @@
+package mmaconv
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// MinDelta and MaxDelta are the bounds of the step of the sequence counter
+// between two records of the same file at 1500 Hz, beyond them the counter is
+// considered reset. At other frequencies, the bounds are AvgCount records.
+const (
+	AvgCount = 219
+	MinDelta = -AvgCount * MeasCount
+	MaxDelta = AvgCount * MeasCount
+)
+
+const (
+	wrapSize   = MaxSequence + 1
+	resetLimit = wrapSize / 4
+)
+
+type Reason uint8
+
+const (
+	ReasonNone Reason = iota
+	// the sequence counter wrapped around MaxSequence
+	ReasonWrap
+	// the sequence counter restarted at an unrelated value
+	ReasonReset
+	// the record arrived after records that follow it
+	ReasonReorder
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonNone:
+		return ""
+	case ReasonWrap:
+		return "wrap"
+	case ReasonReset:
+		return "reset"
+	case ReasonReorder:
+		return "reorder"
+	default:
+		return "unknown"
+	}
+}
+
+// Timeline unwraps the 16-bit milbus sequence counter of records into a
+// monotonic counter. The same Timeline can be used for consecutive files: the
+// acquisition times of the files are then used to unwrap the counter across
+// file boundaries.
+type Timeline struct {
+	freq    int64
+	nominal int64
+
+	started bool
+	last    uint16
+	count   int64
+	max     int64
+	vid     uint32
+	when    time.Time
+	// count of the first record of the current file
+	first int64
+
+	// last count of the ordered records
+	ordered bool
+	prev    int64
+}
+
+// NewTimeline creates a Timeline for a sensor sampling at freq Hz. The
+// nominal step of the sequence counter between two records is given by
+// Frequencies (1500 Hz is assumed for unknown frequencies).
+func NewTimeline(freq int64) *Timeline {
+	step, ok := Frequencies[freq]
+	if !ok {
+		step = MeasCount
+	}
+	return &Timeline{
+		freq:    freq,
+		nominal: step,
+	}
+}
+
+func (t *Timeline) Frequency() int64 {
+	return t.freq
+}
+
+// Step gives the nominal step of the sequence counter between two records.
+func (t *Timeline) Step() int64 {
+	return t.nominal
+}
+
+// Order unwraps the sequence counter of each record, sets their Count and
+// Reason and sorts them by Count. Records preceding a reset of the counter in
+// the same file are marked as NoDate. Records whose step from the previous
+// record is greater than one and a half the nominal step are marked with
+// QualityGap.
+func (t *Timeline) Order(data []Record) []Record {
+	for i := range data {
+		data[i].Count, data[i].Reason = t.Unwrap(data[i])
+		if data[i].Reason == ReasonReset {
+			for j := 0; j < i; j++ {
+				data[j].NoDate = true
+			}
+		}
+	}
+	sort.SliceStable(data, func(i, j int) bool {
+		return data[i].Count < data[j].Count
+	})
+	for i := range data {
+		if step := data[i].Count - t.prev; t.ordered && t.nominal > 0 && step > t.nominal+t.nominal/2 {
+			data[i].Flags |= QualityGap
+		}
+		t.prev = data[i].Count
+		t.ordered = true
+	}
+	return data
+}
+
+// Unwrap gives the position of the record on the timeline and the reason why
+// it does not simply follow the previous record.
+func (t *Timeline) Unwrap(rec Record) (int64, Reason) {
+	if !t.started {
+		t.started = true
+		t.max = int64(rec.Seq)
+		t.first = t.max
+		t.update(rec, t.max)
+		return t.max, ReasonNone
+	}
+	var (
+		diff   = int64(int16(rec.Seq - t.last))
+		count  = t.count + diff
+		reason = ReasonNone
+	)
+	next := rec.Vid != t.vid || !rec.When.Equal(t.when)
+	if next {
+		// the acquisition times of the files give the expected step from
+		// the first record of the previous file
+		expected := rec.When.Sub(t.when).Seconds() * TickRate
+		count += int64(math.Round((expected-float64(count-t.first))/wrapSize)) * wrapSize
+		if math.Abs(float64(count-t.first)-expected) > resetLimit {
+			reason = ReasonReset
+		}
+	} else if d := t.maxDelta(); diff < -d || diff > d {
+		reason = ReasonReset
+	}
+	switch {
+	case reason == ReasonReset:
+		count = (((t.max >> 16) + 1) << 16) | int64(rec.Seq)
+	case count < t.max:
+		reason = ReasonReorder
+	case count>>16 > t.count>>16:
+		reason = ReasonWrap
+	}
+	if count > t.max {
+		t.max = count
+	}
+	if next || reason == ReasonReset {
+		t.first = count
+	}
+	t.update(rec, count)
+	return count, reason
+}
+
+// maxDelta gives the bound of the step between two records of the same file.
+// It is kept below half the range of the counter for the step to be computed
+// from the 16-bit difference.
+func (t *Timeline) maxDelta() int64 {
+	if t.nominal == 0 {
+		return MaxDelta
+	}
+	d := AvgCount * t.nominal
+	if limit := int64(wrapSize/2 - 1); d > limit {
+		d = limit - (limit % t.nominal)
+	}
+	return d
+}
+
+func (t *Timeline) update(rec Record, count int64) {
+	t.last = rec.Seq
+	t.count = count
+	t.vid = rec.Vid
+	t.when = rec.When
+}
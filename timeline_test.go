@@ -0,0 +1,147 @@
+package mmaconv
+
+import (
+	"testing"
+	"time"
+)
+
+var timelineEpoch = time.Date(2021, 5, 29, 10, 10, 10, 0, time.UTC)
+
+type timelineWant struct {
+	Count  int64
+	Reason Reason
+	NoDate bool
+}
+
+func makeRecords(vid uint32, when time.Time, seqs ...uint16) []Record {
+	rs := make([]Record, len(seqs))
+	for i, s := range seqs {
+		rs[i] = Record{Seq: s, Vid: vid, When: when}
+	}
+	return rs
+}
+
+// makeFile gives the n records of a file sampled at freq Hz whose first record
+// has the sequence counter seq.
+func makeFile(vid uint32, when time.Time, freq int64, seq uint16, n int) []Record {
+	seqs := make([]uint16, n)
+	for i := range seqs {
+		seqs[i] = seq + uint16(int64(i)*Frequencies[freq])
+	}
+	return makeRecords(vid, when, seqs...)
+}
+
+func checkTimeline(t *testing.T, got []Record, want []timelineWant) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("records: want %d, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Count != w.Count || g.Reason != w.Reason || g.NoDate != w.NoDate {
+			t.Errorf("record %d: want %d/%q/%t, got %d/%q/%t", i, w.Count, w.Reason, w.NoDate, g.Count, g.Reason, g.NoDate)
+		}
+	}
+}
+
+func TestTimelineWrap(t *testing.T) {
+	rs := makeRecords(1, timelineEpoch, 65520, 65529, 2, 11)
+	want := []timelineWant{
+		{Count: 65520},
+		{Count: 65529},
+		{Count: 65538, Reason: ReasonWrap},
+		{Count: 65547},
+	}
+	checkTimeline(t, NewTimeline(1500).Order(rs), want)
+}
+
+func TestTimelineReset(t *testing.T) {
+	rs := makeRecords(1, timelineEpoch, 100, 109, 30000, 30009)
+	want := []timelineWant{
+		{Count: 100, NoDate: true},
+		{Count: 109, NoDate: true},
+		{Count: wrapSize + 30000, Reason: ReasonReset},
+		{Count: wrapSize + 30009},
+	}
+	checkTimeline(t, NewTimeline(1500).Order(rs), want)
+}
+
+func TestTimelineReorder(t *testing.T) {
+	rs := makeRecords(1, timelineEpoch, 100, 118, 109, 127)
+	want := []timelineWant{
+		{Count: 100},
+		{Count: 109, Reason: ReasonReorder},
+		{Count: 118},
+		{Count: 127},
+	}
+	checkTimeline(t, NewTimeline(1500).Order(rs), want)
+}
+
+func TestTimelineAcrossFiles(t *testing.T) {
+	var (
+		tl   = NewTimeline(1500)
+		curr = makeRecords(1, timelineEpoch, 65000, 65009)
+		next = makeRecords(2, timelineEpoch.Add(time.Second), 964, 973)
+	)
+	checkTimeline(t, tl.Order(curr), []timelineWant{
+		{Count: 65000},
+		{Count: 65009},
+	})
+	checkTimeline(t, tl.Order(next), []timelineWant{
+		{Count: 66500, Reason: ReasonWrap},
+		{Count: 66509},
+	})
+
+	data := []struct {
+		Freq  int64
+		Count int
+	}{
+		{Freq: 1500, Count: 2000},
+		{Freq: 150, Count: 220},
+		{Freq: 50, Count: 219},
+		{Freq: 5, Count: 30},
+	}
+	for _, d := range data {
+		var (
+			tl   = NewTimeline(d.Freq)
+			step = Frequencies[d.Freq]
+			span = time.Duration(int64(d.Count)*step) * time.Second / TickRate
+			when = timelineEpoch
+			seq  = uint16(1000)
+			want = int64(seq)
+		)
+		for i := 0; i < 5; i++ {
+			rs := tl.Order(makeFile(uint32(i+1), when, d.Freq, seq, d.Count))
+			for j, r := range rs {
+				if r.Count != want || r.Reason == ReasonReset || r.NoDate || r.Flags.Has(QualityGap) {
+					t.Fatalf("%d Hz: file %d, record %d: want count %d, got %d (reason %q, gap %t)", d.Freq, i, j, want, r.Count, r.Reason, r.Flags.Has(QualityGap))
+				}
+				want += step
+			}
+			when = when.Add(span)
+			seq += uint16(int64(d.Count) * step)
+		}
+	}
+}
+
+func TestTimelineLowFrequency(t *testing.T) {
+	step := Frequencies[5]
+	rs := makeRecords(1, timelineEpoch, 60000, uint16(60000+step), uint16(60000+2*step), uint16(60000+3*step))
+	want := []timelineWant{
+		{Count: 60000},
+		{Count: 60000 + step},
+		{Count: 60000 + 2*step},
+		{Count: 60000 + 3*step, Reason: ReasonWrap},
+	}
+	checkTimeline(t, NewTimeline(5).Order(rs), want)
+}
+
+func TestTimelineGap(t *testing.T) {
+	rs := makeRecords(1, timelineEpoch, 100, 101, 110, 119, 137, 146)
+	rs = NewTimeline(1500).Order(rs)
+	for i, r := range rs {
+		if want := i == 4; r.Flags.Has(QualityGap) != want {
+			t.Errorf("record %d (sequence %d): gap want %t, got %t", i, r.Seq, want, !want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package mmaconv
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type TimeScale uint8
+
+const (
+	GPS TimeScale = iota
+	UTC
+	TAI
+)
+
+// offset between TAI and GPS time
+const taiOffset = 19 * time.Second
+
+type leapSecond struct {
+	When   time.Time
+	Offset time.Duration
+}
+
+// leapSeconds gives the difference between GPS time and UTC from the date (UTC)
+// each leap second was introduced.
+var leapSeconds = []leapSecond{
+	{When: time.Date(1981, 7, 1, 0, 0, 0, 0, time.UTC), Offset: 1 * time.Second},
+	{When: time.Date(1982, 7, 1, 0, 0, 0, 0, time.UTC), Offset: 2 * time.Second},
+	{When: time.Date(1983, 7, 1, 0, 0, 0, 0, time.UTC), Offset: 3 * time.Second},
+	{When: time.Date(1985, 7, 1, 0, 0, 0, 0, time.UTC), Offset: 4 * time.Second},
+	{When: time.Date(1988, 1, 1, 0, 0, 0, 0, time.UTC), Offset: 5 * time.Second},
+	{When: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Offset: 6 * time.Second},
+	{When: time.Date(1991, 1, 1, 0, 0, 0, 0, time.UTC), Offset: 7 * time.Second},
+	{When: time.Date(1992, 7, 1, 0, 0, 0, 0, time.UTC), Offset: 8 * time.Second},
+	{When: time.Date(1993, 7, 1, 0, 0, 0, 0, time.UTC), Offset: 9 * time.Second},
+	{When: time.Date(1994, 7, 1, 0, 0, 0, 0, time.UTC), Offset: 10 * time.Second},
+	{When: time.Date(1996, 1, 1, 0, 0, 0, 0, time.UTC), Offset: 11 * time.Second},
+	{When: time.Date(1997, 7, 1, 0, 0, 0, 0, time.UTC), Offset: 12 * time.Second},
+	{When: time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC), Offset: 13 * time.Second},
+	{When: time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC), Offset: 14 * time.Second},
+	{When: time.Date(2009, 1, 1, 0, 0, 0, 0, time.UTC), Offset: 15 * time.Second},
+	{When: time.Date(2012, 7, 1, 0, 0, 0, 0, time.UTC), Offset: 16 * time.Second},
+	{When: time.Date(2015, 7, 1, 0, 0, 0, 0, time.UTC), Offset: 17 * time.Second},
+	{When: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), Offset: 18 * time.Second},
+}
+
+// LeapSeconds gives the difference between GPS time and UTC at the given GPS
+// time.
+func LeapSeconds(gps time.Time) time.Duration {
+	var offset time.Duration
+	for _, s := range leapSeconds {
+		if gps.Before(s.When.Add(s.Offset)) {
+			break
+		}
+		offset = s.Offset
+	}
+	return offset
+}
+
+func leapSecondsUTC(utc time.Time) time.Duration {
+	var offset time.Duration
+	for _, s := range leapSeconds {
+		if utc.Before(s.When) {
+			break
+		}
+		offset = s.Offset
+	}
+	return offset
+}
+
+func (s *TimeScale) Set(str string) error {
+	switch strings.ToLower(str) {
+	case "gps", "":
+		*s = GPS
+	case "utc":
+		*s = UTC
+	case "tai":
+		*s = TAI
+	default:
+		return fmt.Errorf("%s: unknown time scale", str)
+	}
+	return nil
+}
+
+func (s TimeScale) String() string {
+	switch s {
+	case GPS:
+		return "gps"
+	case UTC:
+		return "utc"
+	case TAI:
+		return "tai"
+	default:
+		return "unknown"
+	}
+}
+
+// FromGPS converts a GPS time to the time scale.
+func (s TimeScale) FromGPS(t time.Time) time.Time {
+	switch s {
+	case UTC:
+		return t.Add(-LeapSeconds(t))
+	case TAI:
+		return t.Add(taiOffset)
+	default:
+		return t
+	}
+}
+
+// ToGPS converts a time given in the time scale to GPS time.
+func (s TimeScale) ToGPS(t time.Time) time.Time {
+	switch s {
+	case UTC:
+		return t.Add(leapSecondsUTC(t))
+	case TAI:
+		return t.Add(-taiOffset)
+	default:
+		return t
+	}
+}
+
+// Convert converts a time given in the time scale to the other time scale.
+func (s TimeScale) Convert(t time.Time, to TimeScale) time.Time {
+	if s == to {
+		return t
+	}
+	return to.FromGPS(s.ToGPS(t))
+}
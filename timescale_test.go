@@ -0,0 +1,58 @@
+package mmaconv
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeScaleLeapSecond(t *testing.T) {
+	data := []struct {
+		UTC    time.Time
+		GPS    time.Time
+		Offset time.Duration
+	}{
+		{
+			UTC:    time.Date(2016, 12, 31, 23, 59, 59, 0, time.UTC),
+			GPS:    time.Date(2017, 1, 1, 0, 0, 16, 0, time.UTC),
+			Offset: 17 * time.Second,
+		},
+		{
+			UTC:    time.Date(2016, 12, 31, 23, 59, 59, 999999999, time.UTC),
+			GPS:    time.Date(2017, 1, 1, 0, 0, 16, 999999999, time.UTC),
+			Offset: 17 * time.Second,
+		},
+		{
+			UTC:    time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+			GPS:    time.Date(2017, 1, 1, 0, 0, 18, 0, time.UTC),
+			Offset: 18 * time.Second,
+		},
+		{
+			UTC:    time.Date(2017, 1, 1, 0, 0, 1, 0, time.UTC),
+			GPS:    time.Date(2017, 1, 1, 0, 0, 19, 0, time.UTC),
+			Offset: 18 * time.Second,
+		},
+	}
+	for _, d := range data {
+		if got := LeapSeconds(d.GPS); got != d.Offset {
+			t.Errorf("%s: leap seconds at gps time: want %s, got %s", d.UTC, d.Offset, got)
+		}
+		if got := leapSecondsUTC(d.UTC); got != d.Offset {
+			t.Errorf("%s: leap seconds at utc time: want %s, got %s", d.UTC, d.Offset, got)
+		}
+		if got := UTC.ToGPS(d.UTC); !got.Equal(d.GPS) {
+			t.Errorf("%s: gps time: want %s, got %s", d.UTC, d.GPS, got)
+		}
+		if got := UTC.FromGPS(d.GPS); !got.Equal(d.UTC) {
+			t.Errorf("%s: utc time: want %s, got %s", d.GPS, d.UTC, got)
+		}
+		if got := UTC.ToGPS(UTC.FromGPS(d.GPS)); !got.Equal(d.GPS) {
+			t.Errorf("%s: gps time after round trip: want %s, got %s", d.GPS, d.GPS, got)
+		}
+		if got := GPS.Convert(d.GPS, TAI); !got.Equal(d.GPS.Add(taiOffset)) {
+			t.Errorf("%s: tai time: want %s, got %s", d.GPS, d.GPS.Add(taiOffset), got)
+		}
+		if got := TAI.Convert(d.GPS.Add(taiOffset), UTC); !got.Equal(d.UTC) {
+			t.Errorf("%s: utc time from tai: want %s, got %s", d.GPS, d.UTC, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package mmaconv
+
+import "math"
+
+// Quantisation is the standard uncertainty (in counts) of a raw value due to
+// its quantisation.
+var Quantisation = 1 / math.Sqrt(12)
+
+// Uncertainty holds the standard uncertainties of the coefficients of a table
+// and of the raw channels. The uncertainties of the raw temperatures and
+// accelerations are given in counts and are combined with Quantisation.
+type Uncertainty struct {
+	Scale        XYZ
+	Offset       XYZ
+	Temperature  XYZ
+	Acceleration XYZ
+
+	AxisX ABC `toml:"x-axis"`
+	AxisY ABC `toml:"y-axis"`
+	AxisZ ABC `toml:"z-axis"`
+}
+
+// channel gathers the coefficients of an axis and their uncertainties.
+type channel struct {
+	ABC
+	Sigma ABC
+
+	Scale       float64
+	Offset      float64
+	SigmaScale  float64
+	SigmaOffset float64
+	SigmaTemp   float64
+	SigmaAcc    float64
+}
+
+func (t Table) channels() []channel {
+	var (
+		u  = t.Sigma
+		cs []channel
+	)
+	for i, a := range t.axes() {
+		c := channel{
+			ABC:    a.ABC,
+			Scale:  a.Scale,
+			Offset: a.Offset,
+		}
+		switch i {
+		case 0:
+			c.Sigma, c.SigmaScale, c.SigmaOffset = u.AxisX, u.Scale.X, u.Offset.X
+			c.SigmaTemp, c.SigmaAcc = u.Temperature.X, u.Acceleration.X
+		case 1:
+			c.Sigma, c.SigmaScale, c.SigmaOffset = u.AxisY, u.Scale.Y, u.Offset.Y
+			c.SigmaTemp, c.SigmaAcc = u.Temperature.Y, u.Acceleration.Y
+		case 2:
+			c.Sigma, c.SigmaScale, c.SigmaOffset = u.AxisZ, u.Scale.Z, u.Offset.Z
+			c.SigmaTemp, c.SigmaAcc = u.Temperature.Z, u.Acceleration.Z
+		}
+		c.SigmaTemp = math.Hypot(c.SigmaTemp, Quantisation)
+		c.SigmaAcc = math.Hypot(c.SigmaAcc, Quantisation)
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// temperature gives the uncertainty (degree celsius) of the temperature
+// computed from a raw temperature word.
+func (c channel) temperature(mica float64) float64 {
+	var sum float64
+	sum += sq(2.803e-03 / c.A1 * c.SigmaTemp)
+	sum += sq(c.Sigma.A0 / c.A1)
+	sum += sq((mica - c.A0) / (c.A1 * c.A1) * c.Sigma.A1)
+	return math.Sqrt(sum)
+}
+
+// acceleration gives the uncertainty (micro g) of the acceleration computed
+// from a raw acceleration word and the compensation value of the temperature
+// (mica - reference).
+func (c channel) acceleration(raw, ai float64) float64 {
+	var (
+		p   = c.C0 + (c.C1 * ai) + (c.C2 * ai * ai) + (c.C3 * math.Pow(ai, 3)) + (c.C4 * math.Pow(ai, 4))
+		dp  = c.C1 + (2 * c.C2 * ai) + (3 * c.C3 * ai * ai) + (4 * c.C4 * math.Pow(ai, 3))
+		do  = c.B1 + (2 * c.B2 * ai) + (3 * c.B3 * ai * ai) + (4 * c.B4 * math.Pow(ai, 3))
+		sf  = c.Scale * c.C0 / p
+		dsf = -c.Scale * c.C0 * dp / (p * p)
+		sum float64
+	)
+	// raw values
+	sum += sq(sf * c.SigmaAcc)
+	sum += sq(((raw * dsf) - do) * 2.803e-03 * c.SigmaTemp)
+
+	// scale factor and offset
+	sum += sq(raw * c.C0 / p * c.SigmaScale)
+	sum += sq(c.SigmaOffset)
+
+	// polynomials of the offset
+	sum += sq(ai * c.Sigma.B1)
+	sum += sq(ai * ai * c.Sigma.B2)
+	sum += sq(math.Pow(ai, 3) * c.Sigma.B3)
+	sum += sq(math.Pow(ai, 4) * c.Sigma.B4)
+
+	// polynomials of the scale factor
+	var (
+		pp = p * p
+		k  = raw * c.Scale * c.C0 / pp
+	)
+	sum += sq(raw * c.Scale * (p - c.C0) / pp * c.Sigma.C0)
+	sum += sq(k * ai * c.Sigma.C1)
+	sum += sq(k * ai * ai * c.Sigma.C2)
+	sum += sq(k * math.Pow(ai, 3) * c.Sigma.C3)
+	sum += sq(k * math.Pow(ai, 4) * c.Sigma.C4)
+
+	return math.Sqrt(sum)
+}
+
+func (c channel) accelerations(raw []float64, ai float64) []float64 {
+	var vs []float64
+	for _, r := range raw {
+		vs = append(vs, c.acceleration(r, ai))
+	}
+	return vs
+}
+
+func sq(v float64) float64 {
+	return v * v
+}
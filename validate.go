@@ -0,0 +1,199 @@
+package mmaconv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/midbel/toml"
+)
+
+var (
+	ErrFrequency = errors.New("unsupported frequency")
+	ErrDivisor   = errors.New("zero or NaN divisor")
+	ErrMissing   = errors.New("missing")
+)
+
+// FieldError reports a problem found with a field of a table. Field uses the
+// names of the toml file (eg: x-axis.A1).
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that the table can be used to calibrate records: the
+// frequency has to be one of Frequencies and the divisors (A1 and C0) of each
+// axis can not be zero or NaN.
+func (t Table) Validate() []error {
+	var list []error
+	if _, ok := Frequencies[t.Frequency]; !ok {
+		list = append(list, &FieldError{Field: "frequency", Err: fmt.Errorf("%w %d", ErrFrequency, t.Frequency)})
+	}
+	if _, err := t.Calibrator(); err != nil {
+		list = append(list, &FieldError{Field: "model", Err: err})
+	}
+	if err := t.Limits.validate(); err != nil {
+		list = append(list, &FieldError{Field: "limits", Err: err})
+	}
+	if _, err := t.Align.Rotation(); err != nil {
+		list = append(list, &FieldError{Field: "alignment.matrix", Err: err})
+	}
+	for _, a := range t.axes() {
+		if a.A1 == 0 || math.IsNaN(a.A1) {
+			list = append(list, &FieldError{Field: a.Name + "-axis.A1", Err: ErrDivisor})
+		}
+		if a.C0 == 0 || math.IsNaN(a.C0) {
+			list = append(list, &FieldError{Field: a.Name + "-axis.C0", Err: ErrDivisor})
+		}
+	}
+	return list
+}
+
+// CheckTable decodes a table file and reports the sections and values that are
+// missing from the file in addition to the problems found by Validate.
+func CheckTable(file string) (Table, []error) {
+	var (
+		tbl  Table
+		keys = make(map[string]interface{})
+	)
+	if err := toml.DecodeFile(file, &tbl); err != nil {
+		return tbl, []error{err}
+	}
+	if err := toml.DecodeFile(file, &keys); err != nil {
+		return tbl, []error{err}
+	}
+	tbl.setReference(keys)
+
+	var list []error
+	if _, ok := keys["frequency"]; !ok {
+		list = append(list, &FieldError{Field: "frequency", Err: ErrMissing})
+	}
+	for _, s := range sections {
+		sub, ok := keys[s.Name].(map[string]interface{})
+		if !ok {
+			if !s.Optional {
+				list = append(list, &FieldError{Field: s.Name, Err: ErrMissing})
+			}
+			continue
+		}
+		for _, k := range s.Keys {
+			if _, ok := sub[k]; !ok {
+				list = append(list, &FieldError{Field: s.Name + "." + k, Err: ErrMissing})
+			}
+		}
+	}
+	return tbl, append(list, tbl.Validate()...)
+}
+
+var (
+	xyzKeys = []string{"X", "Y", "Z"}
+	abcKeys = []string{"A0", "A1", "B0", "B1", "B2", "B3", "B4", "C0", "C1", "C2", "C3", "C4"}
+)
+
+// sections of a table file. The reference temperatures and the zero points
+// are optional since TempMMA and TempZero are used when they are not given.
+var sections = []struct {
+	Name     string
+	Keys     []string
+	Optional bool
+}{
+	{Name: "calibration", Keys: xyzKeys, Optional: true},
+	{Name: "zero", Keys: xyzKeys, Optional: true},
+	{Name: "scale", Keys: xyzKeys},
+	{Name: "offset", Keys: xyzKeys},
+	{Name: "x-axis", Keys: abcKeys},
+	{Name: "y-axis", Keys: abcKeys},
+	{Name: "z-axis", Keys: abcKeys},
+}
+
+// Difference is a value of a table that differs from the one of another table.
+type Difference struct {
+	Field string
+	Old   float64
+	New   float64
+}
+
+// Diff gives the values of other that differ from the ones of the table.
+func (t Table) Diff(other Table) []Difference {
+	var (
+		old  = t.values()
+		curr = other.values()
+		list []Difference
+	)
+	for i := range old {
+		a, b := old[i].Value, curr[i].Value
+		if a == b || (math.IsNaN(a) && math.IsNaN(b)) {
+			continue
+		}
+		list = append(list, Difference{Field: old[i].Field, Old: a, New: b})
+	}
+	return list
+}
+
+type value struct {
+	Field string
+	Value float64
+}
+
+func (t Table) values() []value {
+	vs := []value{{Field: "frequency", Value: float64(t.Frequency)}}
+	vs = appendXYZ(vs, "calibration", t.Calib)
+	vs = appendXYZ(vs, "zero", t.Zero)
+	vs = appendXYZ(vs, "scale", t.Scale)
+	vs = appendXYZ(vs, "offset", t.Offset)
+	vs = appendABC(vs, "x-axis", t.AxisX)
+	vs = appendABC(vs, "y-axis", t.AxisY)
+	vs = appendABC(vs, "z-axis", t.AxisZ)
+	vs = appendXYZ(vs, "uncertainty.scale", t.Sigma.Scale)
+	vs = appendXYZ(vs, "uncertainty.offset", t.Sigma.Offset)
+	vs = appendXYZ(vs, "uncertainty.temperature", t.Sigma.Temperature)
+	vs = appendXYZ(vs, "uncertainty.acceleration", t.Sigma.Acceleration)
+	vs = appendABC(vs, "uncertainty.x-axis", t.Sigma.AxisX)
+	vs = appendABC(vs, "uncertainty.y-axis", t.Sigma.AxisY)
+	vs = appendABC(vs, "uncertainty.z-axis", t.Sigma.AxisZ)
+	rot, _ := t.Align.Rotation()
+	for i, r := range rot {
+		vs = appendXYZ(vs, fmt.Sprintf("alignment.matrix[%d]", i), XYZ{X: r[0], Y: r[1], Z: r[2]})
+	}
+	vs = appendXYZ(vs, "alignment.lever", t.Align.Lever)
+	vs = appendXYZ(vs, "alignment.rate", t.Align.Rate)
+	vs = appendRange(vs, "limits.x-axis.temperature", t.Limits.AxisX.Temperature)
+	vs = appendRange(vs, "limits.y-axis.temperature", t.Limits.AxisY.Temperature)
+	vs = appendRange(vs, "limits.z-axis.temperature", t.Limits.AxisZ.Temperature)
+	vs = appendRange(vs, "limits.x-axis.acceleration", t.Limits.AxisX.Acceleration)
+	vs = appendRange(vs, "limits.y-axis.acceleration", t.Limits.AxisY.Acceleration)
+	vs = appendRange(vs, "limits.z-axis.acceleration", t.Limits.AxisZ.Acceleration)
+	return vs
+}
+
+func appendXYZ(vs []value, name string, v XYZ) []value {
+	for i, f := range []float64{v.X, v.Y, v.Z} {
+		vs = append(vs, value{Field: name + "." + xyzKeys[i], Value: f})
+	}
+	return vs
+}
+
+func appendABC(vs []value, name string, a ABC) []value {
+	fs := []float64{a.A0, a.A1, a.B0, a.B1, a.B2, a.B3, a.B4, a.C0, a.C1, a.C2, a.C3, a.C4}
+	for i, f := range fs {
+		vs = append(vs, value{Field: name + "." + abcKeys[i], Value: f})
+	}
+	return vs
+}
+
+func appendRange(vs []value, name string, r []float64) []value {
+	min, max := math.NaN(), math.NaN()
+	if len(r) == 2 {
+		min, max = r[0], r[1]
+	}
+	vs = append(vs, value{Field: name + ".min", Value: min})
+	return append(vs, value{Field: name + ".max", Value: max})
+}